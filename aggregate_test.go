@@ -1,6 +1,7 @@
 package csvdata_test
 
 import (
+	"math"
 	"testing"
 
 	"github.com/luhtfiimanal/csvdata"
@@ -147,3 +148,42 @@ func BenchmarkAggregatorThousand(t *testing.B) {
 		})
 	}
 }
+
+func TestPickMode(t *testing.T) {
+	// unordered sparse data
+	data := []csvdata.Input{
+		{Epoch: 20, Value: 30},
+		{Epoch: 0, Value: 0},
+		{Epoch: 60, Value: 60},
+		{Epoch: 10, Value: 10},
+	}
+	tests := []struct {
+		name   string
+		picker csvdata.PickerDate
+		want   float64
+	}{
+		{"Nearest", csvdata.PickerDate{PickEpoch: 16}, 30},
+		{"Previous", csvdata.PickerDate{PickEpoch: 16, PickMode: csvdata.PREVIOUS}, 10},
+		{"Next", csvdata.PickerDate{PickEpoch: 16, PickMode: csvdata.NEXT}, 30},
+		{"Linear", csvdata.PickerDate{PickEpoch: 16, PickMode: csvdata.LINEAR}, 22},
+		{"OutOfTolerance", csvdata.PickerDate{PickEpoch: 40, PickToleranceEp: 10}, math.NaN()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg := csvdata.NewAggregator(csvdata.PICK)
+			picker := tt.picker
+			agg.PickerDate = &picker
+			go func() {
+				for _, val := range data {
+					agg.Data <- val
+				}
+				close(agg.Data)
+			}()
+			result := <-agg.Done
+			if result.Value != tt.want && !(math.IsNaN(result.Value) && math.IsNaN(tt.want)) {
+				t.Errorf("got %v, want %v", result.Value, tt.want)
+			}
+		})
+	}
+}
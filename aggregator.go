@@ -13,6 +13,8 @@ const (
 	LAST  = "last"
 	FIRST = "first"
 	PICK  = "pick"
+
+	RESAMPLE = "resample" // the sample nearest to the result time, only for CsvAggregateTable
 )
 
 func NewAggregator(agg string) *Aggregator {
@@ -25,7 +27,9 @@ func NewAggregator(agg string) *Aggregator {
 }
 
 type PickerDate struct {
-	PickEpoch int64
+	PickEpoch       int64
+	PickMode        string // NEAREST, PREVIOUS, NEXT or LINEAR, empty means NEAREST
+	PickToleranceEp int64  // maximum distance to a picked sample, zero means no limit
 }
 
 type Aggregator struct {
@@ -39,10 +43,13 @@ type Aggregator struct {
 type Input struct {
 	Epoch int64
 	Value float64
+	tick  bool // no value, only tells the stream aggregators that the time went on
 }
 
 type result struct {
 	Value float64
+	Epoch int64 // epoch of the picked sample, only for PICK
+	Ok    bool  // false when no sample is within the pick tolerance, only for PICK
 }
 
 func (a *Aggregator) Do() {
@@ -113,18 +120,19 @@ func (a *Aggregator) doLast() {
 }
 
 func (a *Aggregator) doPick() {
-	// pick nearest value from the picker date
-	var pick float64
-	var pickepochdist int64
-	pickepochdist = math.MaxInt64
+	// find the nearest sample at or before and at or after the picker date
+	var prev, next *Input
 	for val := range a.Data {
-		epochdist := int64(math.Abs(float64(val.Epoch - a.PickEpoch)))
-		if epochdist < pickepochdist {
-			pickepochdist = epochdist
-			pick = val.Value
+		val := val
+		if val.Epoch <= a.PickEpoch && (prev == nil || val.Epoch > prev.Epoch) {
+			prev = &val
+		}
+		if val.Epoch >= a.PickEpoch && (next == nil || val.Epoch < next.Epoch) {
+			next = &val
 		}
 	}
-	a.Done <- result{Value: pick}
+	pick, epoch, ok := pickValue(a.PickMode, a.PickEpoch, a.PickToleranceEp, prev, next)
+	a.Done <- result{Value: pick, Epoch: epoch, Ok: ok}
 	close(a.Done)
 }
 
@@ -0,0 +1,106 @@
+package csvdata_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+// writeRenamed copies the example files in a fixture directory, temperature_avg_60 is renamed to air_temp_avg_60 from 2023-01-11
+func writeRenamed(t *testing.T) string {
+	dir := fixtureDir(t)
+	for _, day := range []string{"2023-01-10", "2023-01-11"} {
+		content := string(readExample(t, day+".csv"))
+		if day == "2023-01-11" {
+			header := content[:strings.Index(content, "\n")]
+			content = strings.Replace(header, ",temperature_avg_60,", ",air_temp_avg_60,", 1) + content[len(header):]
+		}
+		writeFixture(t, filepath.Join(dir, day+".csv"), []byte(content))
+	}
+	return dir
+}
+
+func TestCsvAggregatePoint_InputAliases(t *testing.T) {
+	dir := writeRenamed(t)
+
+	cfg := csvdata.CsvAggregatePointConfigs{
+		FileConfig: csvdata.FileConfig{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		Requests: []csvdata.RequestColumn{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "count", Method: csvdata.COUNT},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "max", Method: csvdata.MAX},
+		},
+		StartTime:     time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 11, 12, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+	}
+	want, err := csvdata.CsvAggregatePoint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.FileConfig.FileNamingFormat = filepath.Join(dir, "2006-01-02.csv")
+	half, err := csvdata.CsvAggregatePoint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if half["count"] >= want["count"] {
+		t.Fatalf("the renamed column is read without alias, got %v", half)
+	}
+
+	for i := range cfg.Requests {
+		cfg.Requests[i].InputAliases = []string{"air_temp_avg_60"}
+	}
+	got, err := csvdata.CsvAggregatePoint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["count"] != want["count"] || got["max"] != want["max"] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCsvAggregateTable_PeriodColumns(t *testing.T) {
+	dir := writeRenamed(t)
+
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "mean", Method: csvdata.MEAN},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "count", Method: csvdata.COUNT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 11, 12, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "3h",
+	}
+	want, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the rename is only known in the period after it
+	change := time.Date(2023, 1, 11, 0, 0, 0, 0, time.UTC)
+	cfg.FileConfigs = []csvdata.FileConfig{{
+		Periods: []csvdata.FilePeriod{
+			{Until: change, FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"), FileFrequency: "24h"},
+			{From: change, FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"), FileFrequency: "24h",
+				Columns: map[string]string{"temperature_avg_60": "air_temp_avg_60"}},
+		},
+	}}
+	got, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"mean", "count"} {
+		for i, v := range *want.Columns[col] {
+			if (*got.Columns[col])[i] != v {
+				t.Errorf("%s row %d got %v, want %v", col, i, (*got.Columns[col])[i], v)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package csvdata
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow/go/v11/arrow/ipc"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+)
+
+// ArrowOptions controls how WriteArrowStream and WriteArrowFile write a SAResult
+type ArrowOptions struct {
+	TimeColumnName string // name of the timestamp column, empty means "time"
+	TimeUnit       string // SECOND, MILLI, MICRO or NANO unit of the timestamp column, empty means MILLI
+	BatchSize      int    // maximum rows of one record batch, zero means one record batch
+}
+
+// check sets the defaults and checks the options
+func (opts *ArrowOptions) check() error {
+	if opts.TimeColumnName == "" {
+		opts.TimeColumnName = "time"
+	}
+	if opts.TimeUnit == "" {
+		opts.TimeUnit = MILLI
+	}
+	if !StringInSlice(opts.TimeUnit, []string{SECOND, MILLI, MICRO, NANO}) {
+		return fmt.Errorf("arrow time unit %s is not valid", opts.TimeUnit)
+	}
+	return nil
+}
+
+// WriteArrowStream writes the SAResult to w in the Arrow IPC stream format. The record batches
+// have a timestamp column and a nullable float64 column for each request in request order,
+// NaN values are null. The requests are stored as json in the "csvdata.requests" schema metadata.
+func (result SAResult) WriteArrowStream(w io.Writer, opts ArrowOptions) error {
+	if err := opts.check(); err != nil {
+		return err
+	}
+	schema, err := result.arrowSchema(opts.TimeColumnName, opts.TimeUnit)
+	if err != nil {
+		return err
+	}
+
+	writer := ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(memory.NewGoAllocator()))
+	err = result.writeRecords(schema, opts.TimeUnit, opts.BatchSize, writer.Write)
+	if err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// WriteArrowFile writes the SAResult to w in the Arrow IPC file format, also known as Feather v2.
+// The content is the same as WriteArrowStream.
+func (result SAResult) WriteArrowFile(w io.Writer, opts ArrowOptions) error {
+	if err := opts.check(); err != nil {
+		return err
+	}
+	schema, err := result.arrowSchema(opts.TimeColumnName, opts.TimeUnit)
+	if err != nil {
+		return err
+	}
+
+	writer, err := ipc.NewFileWriter(&positionWriter{w: w}, ipc.WithSchema(schema), ipc.WithAllocator(memory.NewGoAllocator()))
+	if err != nil {
+		return err
+	}
+	err = result.writeRecords(schema, opts.TimeUnit, opts.BatchSize, writer.Write)
+	if err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// positionWriter counts the bytes written to w, the arrow file writer only seeks to get its position
+type positionWriter struct {
+	w   io.Writer
+	pos int64
+}
+
+func (pw *positionWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.pos += int64(n)
+	return n, err
+}
+
+func (pw *positionWriter) Seek(offset int64, whence int) (int64, error) {
+	if offset != 0 || whence != io.SeekCurrent {
+		return pw.pos, fmt.Errorf("arrow writer can only get its position")
+	}
+	return pw.pos, nil
+}
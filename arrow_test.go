@@ -0,0 +1,137 @@
+package csvdata_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/ipc"
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func TestWriteArrow(t *testing.T) {
+	rain := []float64{1.25, math.NaN(), 3}
+	temp := []float64{27, 26, 25}
+	timestamp := []time.Time{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+	requests := []csvdata.RequestColumnTable{
+		{InputColumnName: "AT_1200_Avg", OutputColumnName: "temp", Method: csvdata.MEAN},
+		{InputColumnName: "Rain_Tot", OutputColumnName: "rain", Method: csvdata.SUM},
+	}
+	result := csvdata.SAResult{
+		Columns:   csvdata.Columns{"rain": &rain, "temp": &temp},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+
+	t.Run("Stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := result.WriteArrowStream(&buf, csvdata.ArrowOptions{BatchSize: 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		// read it back with the arrow library
+		reader, err := ipc.NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reader.Release()
+		records := []arrow.Record{}
+		for reader.Next() {
+			rec := reader.Record()
+			rec.Retain()
+			defer rec.Release()
+			records = append(records, rec)
+		}
+		if err := reader.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 2 {
+			t.Errorf("got %d record batches, want 2", len(records))
+		}
+		checkArrowRecords(t, reader.Schema(), records, timestamp)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := result.WriteArrowFile(&buf, csvdata.ArrowOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		out := buf.Bytes()
+		if !bytes.HasPrefix(out, []byte("ARROW1\x00\x00")) || !bytes.HasSuffix(out, []byte("ARROW1")) {
+			t.Fatal("arrow magic is missing")
+		}
+
+		// read it back with the arrow library
+		reader, err := ipc.NewFileReader(bytes.NewReader(out))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer reader.Close()
+		records := []arrow.Record{}
+		for i := 0; i < reader.NumRecords(); i++ {
+			rec, err := reader.Record(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rec.Retain()
+			defer rec.Release()
+			records = append(records, rec)
+		}
+		checkArrowRecords(t, reader.Schema(), records, timestamp)
+	})
+}
+
+// checkArrowRecords checks the schema and the values of the test result read back by the arrow library
+func checkArrowRecords(t *testing.T, schema *arrow.Schema, records []arrow.Record, timestamp []time.Time) {
+	t.Helper()
+	if len(schema.Fields()) != 3 || schema.Field(0).Name != "time" || schema.Field(1).Name != "temp" || schema.Field(2).Name != "rain" {
+		t.Fatalf("got schema %v", schema)
+	}
+	if unit := schema.Field(0).Type.(*arrow.TimestampType).Unit; unit != arrow.Millisecond {
+		t.Errorf("got time unit %v", unit)
+	}
+	md := schema.Metadata()
+	if k := md.FindKey("csvdata.requests"); k == -1 || !bytes.Contains([]byte(md.Values()[k]), []byte(`"method":"mean"`)) {
+		t.Errorf("got metadata %v", md)
+	}
+
+	times := []time.Time{}
+	temp := []float64{}
+	rain := []float64{}
+	for _, rec := range records {
+		for r := 0; r < int(rec.NumRows()); r++ {
+			times = append(times, rec.Column(0).(*array.Timestamp).Value(r).ToTime(arrow.Millisecond))
+			temp = append(temp, rec.Column(1).(*array.Float64).Value(r))
+			// NaN is written as null
+			if rec.Column(2).IsNull(r) {
+				rain = append(rain, math.NaN())
+			} else {
+				rain = append(rain, rec.Column(2).(*array.Float64).Value(r))
+			}
+		}
+		if rec.Column(1).NullN() != 0 {
+			t.Error("temp has nulls")
+		}
+	}
+	if len(times) != len(timestamp) {
+		t.Fatalf("got %d rows, want %d", len(times), len(timestamp))
+	}
+	for r := range timestamp {
+		if !times[r].Equal(timestamp[r]) {
+			t.Errorf("row %d: got time %v, want %v", r, times[r], timestamp[r])
+		}
+	}
+	if temp[0] != 27 || temp[1] != 26 || temp[2] != 25 {
+		t.Errorf("got temp %v", temp)
+	}
+	if rain[0] != 1.25 || !math.IsNaN(rain[1]) || rain[2] != 3 {
+		t.Errorf("got rain %v", rain)
+	}
+}
@@ -0,0 +1,88 @@
+package csvdata
+
+import (
+	"fmt"
+	"sync"
+)
+
+// StationPlaceholder is replaced by FileConfig.Station in the file names
+const StationPlaceholder = "{station}"
+
+type CsvAggregateTableBatchConfigs struct {
+	CsvAggregateTableConfigs          // the same for every station, {station} in FileNamingFormat is replaced by the station
+	Stations                 []string // station IDs
+	MaxConcurrency           int      // stations aggregated at the same time, zero means one
+}
+
+// StationResult is the result of one station of CsvAggregateTableBatch
+type StationResult struct {
+	Result SAResult
+	Err    error // the error of CsvAggregateTable for the station, Result is empty when it is set
+}
+
+// cheker function to check if the batch configs are valid, the table configs are checked for every station
+func (cfg *CsvAggregateTableBatchConfigs) Check() error {
+	if len(cfg.Stations) == 0 {
+		return fmt.Errorf("no station to aggregate")
+	}
+	for i, station := range cfg.Stations {
+		if station == "" {
+			return fmt.Errorf("station %d is empty", i)
+		}
+		if findString(cfg.Stations[:i], station) != -1 {
+			return fmt.Errorf("station %s is listed twice", station)
+		}
+	}
+	if cfg.MaxConcurrency < 0 {
+		return fmt.Errorf("MaxConcurrency %d is negative", cfg.MaxConcurrency)
+	}
+	return nil
+}
+
+// CsvAggregateTableBatch runs CsvAggregateTable for every station, with up to MaxConcurrency stations at the same time.
+// The results are keyed by station. An error of one station is in its StationResult and does not stop the others,
+// the returned error is only for invalid batch configs.
+func CsvAggregateTableBatch(cfg CsvAggregateTableBatchConfigs) (map[string]StationResult, error) {
+	if err := cfg.Check(); err != nil {
+		return nil, err
+	}
+
+	maxConcurrency := cfg.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+	sem := make(chan struct{}, maxConcurrency)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make(map[string]StationResult, len(cfg.Stations))
+	for _, station := range cfg.Stations {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(station string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			result, err := CsvAggregateTable(cfg.station(station))
+
+			mu.Lock()
+			results[station] = StationResult{Result: result, Err: err}
+			mu.Unlock()
+		}(station)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// station returns the table configs of one station, nothing is shared with the other stations
+func (cfg *CsvAggregateTableBatchConfigs) station(station string) CsvAggregateTableConfigs {
+	tcfg := cfg.CsvAggregateTableConfigs
+	tcfg.FileConfigs = append([]FileConfig{}, cfg.FileConfigs...)
+	for i := range tcfg.FileConfigs {
+		tcfg.FileConfigs[i].Station = station
+	}
+	// Check fills the requests, so every station has its own
+	tcfg.Requests = append([]RequestColumnTable{}, cfg.Requests...)
+	return tcfg
+}
@@ -0,0 +1,81 @@
+package csvdata_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func TestCsvAggregateTableBatch(t *testing.T) {
+	// the station IDs are digits, they must not be read as a time layout
+	root := fixtureDir(t)
+	data := readExample(t, "2023-01-10.csv")
+	for _, station := range []string{"96001", "96002"} {
+		writeFixture(t, filepath.Join(root, station, station+"_2023-01-10.csv"), data)
+	}
+	// the second source of 96002 has the column too, so its requests are ambiguous
+	writeFixture(t, filepath.Join(root, "96002", "extra_2023-01-10.csv"), []byte("ts,temperature_avg_60\n1673308800,1\n"))
+
+	table := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "max", Method: csvdata.MAX},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "count", Method: csvdata.COUNT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "3h",
+	}
+	want, err := csvdata.CsvAggregateTable(table)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table.FileConfigs = []csvdata.FileConfig{
+		{FileNamingFormat: filepath.Join(root, "{station}", "{station}_2006-01-02.csv"), FileFrequency: "24h"},
+		{FileNamingFormat: filepath.Join(root, "{station}", "extra_2006-01-02.csv"), FileFrequency: "24h"},
+	}
+	cfg := csvdata.CsvAggregateTableBatchConfigs{
+		CsvAggregateTableConfigs: table,
+		Stations:                 []string{"96001", "96002", "96003"},
+		MaxConcurrency:           2,
+	}
+	results, err := csvdata.CsvAggregateTableBatch(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results", len(results))
+	}
+
+	if results["96001"].Err != nil {
+		t.Fatal(results["96001"].Err)
+	}
+	for _, col := range []string{"max", "count"} {
+		for i, v := range *want.Columns[col] {
+			if got := (*results["96001"].Result.Columns[col])[i]; got != v {
+				t.Errorf("%s row %d got %v, want %v", col, i, got, v)
+			}
+		}
+	}
+	if results["96002"].Err == nil {
+		t.Error("expected an ambiguous column error for 96002")
+	}
+	// a station without files has no values
+	if results["96003"].Err != nil {
+		t.Fatal(results["96003"].Err)
+	}
+	if (*results["96003"].Result.Columns["count"])[0] != 0 {
+		t.Errorf("96003 got %v", *results["96003"].Result.Columns["count"])
+	}
+
+	cfg.Stations = []string{"96001", "96001"}
+	if _, err := csvdata.CsvAggregateTableBatch(cfg); err == nil {
+		t.Error("expected an error for a duplicated station")
+	}
+}
@@ -0,0 +1,116 @@
+package csvdata
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	ADD      = "add"
+	SUBTRACT = "subtract"
+	MULTIPLY = "multiply"
+	DIVIDE   = "divide"
+)
+
+// RequestComputedColumn is a second stage request, it computes a new column
+// row by row from columns that already exist in the SAResult
+type RequestComputedColumn struct {
+	InputColumnNames []string
+	OutputColumnName string
+	Operator         string                         // one of ADD, SUBTRACT, MULTIPLY, DIVIDE, applied from left to right
+	Func             func(values []float64) float64 // used instead of Operator when it is set
+}
+
+// cheker function to check if the computed request is valid, known is the list of columns that already exist
+func (req *RequestComputedColumn) check(known []string) error {
+	if req.OutputColumnName == "" {
+		return fmt.Errorf("computed output column name is empty")
+	}
+	if StringInSlice(req.OutputColumnName, known) {
+		return fmt.Errorf("computed output column %s already exists", req.OutputColumnName)
+	}
+	if len(req.InputColumnNames) == 0 {
+		return fmt.Errorf("computed column %s has no input column", req.OutputColumnName)
+	}
+	for _, inp := range req.InputColumnNames {
+		if !StringInSlice(inp, known) {
+			return fmt.Errorf("computed column %s input column %s is not found", req.OutputColumnName, inp)
+		}
+	}
+	if req.Func == nil && !StringInSlice(req.Operator, []string{ADD, SUBTRACT, MULTIPLY, DIVIDE}) {
+		return fmt.Errorf("computed column %s operator %s is not valid", req.OutputColumnName, req.Operator)
+	}
+	return nil
+}
+
+// compute the value of one row, any NaN input gives NaN
+func (req *RequestComputedColumn) compute(values []float64) float64 {
+	for _, v := range values {
+		if math.IsNaN(v) {
+			return math.NaN()
+		}
+	}
+	if req.Func != nil {
+		return req.Func(values)
+	}
+
+	res := values[0]
+	for _, v := range values[1:] {
+		switch req.Operator {
+		case ADD:
+			res += v
+		case SUBTRACT:
+			res -= v
+		case MULTIPLY:
+			res *= v
+		case DIVIDE:
+			// division by zero has no meaningful value
+			if v == 0 {
+				return math.NaN()
+			}
+			res /= v
+		}
+	}
+	return res
+}
+
+// Compute adds the computed columns to the SAResult, requests are processed in order
+// so a computed column can use the computed columns before it
+func (result *SAResult) Compute(reqs []RequestComputedColumn) error {
+	if result.Columns == nil {
+		result.Columns = make(Columns, len(reqs))
+	}
+	if result.Computed == nil {
+		result.Computed = &[]RequestComputedColumn{}
+	}
+
+	known := result.ColumnNames()
+	for i := range reqs {
+		req := reqs[i]
+		if err := req.check(known); err != nil {
+			return err
+		}
+
+		// get the length of the shortest input column
+		length := len(*result.Columns[req.InputColumnNames[0]])
+		for _, inp := range req.InputColumnNames[1:] {
+			if l := len(*result.Columns[inp]); l < length {
+				length = l
+			}
+		}
+
+		values := make([]float64, len(req.InputColumnNames))
+		res := make([]float64, length)
+		for row := range res {
+			for j, inp := range req.InputColumnNames {
+				values[j] = (*result.Columns[inp])[row]
+			}
+			res[row] = req.compute(values)
+		}
+
+		result.Columns[req.OutputColumnName] = &res
+		*result.Computed = append(*result.Computed, req)
+		known = append(known, req.OutputColumnName)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package csvdata_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func TestCompute(t *testing.T) {
+	tmax := []float64{30, 31, math.NaN(), 29}
+	tmin := []float64{22, 24, 23, 0}
+	timestamp := []time.Time{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 4, 0, 0, 0, 0, time.UTC),
+	}
+	requests := []csvdata.RequestColumnTable{
+		{OutputColumnName: "tmax"},
+		{OutputColumnName: "tmin"},
+	}
+	result := csvdata.SAResult{
+		Columns:   csvdata.Columns{"tmax": &tmax, "tmin": &tmin},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+
+	err := result.Compute([]csvdata.RequestComputedColumn{
+		{InputColumnNames: []string{"tmax", "tmin"}, OutputColumnName: "dtr", Operator: csvdata.SUBTRACT},
+		{InputColumnNames: []string{"dtr", "tmin"}, OutputColumnName: "ratio", Operator: csvdata.DIVIDE},
+		{InputColumnNames: []string{"tmax", "tmin"}, OutputColumnName: "tavg", Func: func(v []float64) float64 { return (v[0] + v[1]) / 2 }},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want []float64
+	}{
+		{"dtr", []float64{8, 7, math.NaN(), 29}},
+		{"ratio", []float64{8.0 / 22, 7.0 / 24, math.NaN(), math.NaN()}},
+		{"tavg", []float64{26, 27.5, math.NaN(), 14.5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := *result.Columns[tt.name]
+			for i, v := range got {
+				if v != tt.want[i] && !(math.IsNaN(v) && math.IsNaN(tt.want[i])) {
+					t.Errorf("row %d got %v, want %v", i, v, tt.want[i])
+				}
+			}
+		})
+	}
+
+	// computed columns come after the requests
+	if got := strings.Join(result.ColumnNames(), ","); got != "tmax,tmin,dtr,ratio,tavg" {
+		t.Errorf("got column order %s", got)
+	}
+
+	// unknown input column
+	err = result.Compute([]csvdata.RequestComputedColumn{
+		{InputColumnNames: []string{"tmax", "rain"}, OutputColumnName: "bad", Operator: csvdata.ADD},
+	})
+	if err == nil {
+		t.Error("expected error for unknown input column")
+	}
+}
+
+func TestCsvAggregateTable_Computed(t *testing.T) {
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "tmax", Method: csvdata.MAX},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "tmin", Method: csvdata.MIN},
+		},
+		Computed: []csvdata.RequestComputedColumn{
+			{InputColumnNames: []string{"tmax", "tmin"}, OutputColumnName: "dtr", Operator: csvdata.SUBTRACT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 1, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 6, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "1h",
+	}
+	result, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("SaveToCSV", func(t *testing.T) {
+		filename := filepath.Join(fixtureDir(t), "out.csv")
+		if err := result.SaveToCSV(filename); err != nil {
+			t.Fatal(err)
+		}
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+		if lines[0] != "timeResultEp,tmax,tmin,dtr" || len(lines) != 7 {
+			t.Fatalf("got\n%s", data)
+		}
+		for _, line := range lines[1:] {
+			fields := strings.Split(line, ",")
+			values := make([]float64, 3)
+			for i := range values {
+				if values[i], err = strconv.ParseFloat(fields[i+1], 64); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if math.Abs(values[0]-values[1]-values[2]) > 1e-9 {
+				t.Errorf("dtr is not tmax - tmin in %s", line)
+			}
+		}
+	})
+
+	t.Run("ToJson5", func(t *testing.T) {
+		out, err := result.ToJson5()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmax, tmin, dtr := strings.Index(string(out), `"tmax":[`), strings.Index(string(out), `"tmin":[`), strings.Index(string(out), `"dtr":[`)
+		if tmax == -1 || tmin < tmax || dtr < tmin {
+			t.Errorf("columns are not in request order\n%s", out)
+		}
+		want := strconv.FormatFloat((*result.Columns["dtr"])[0], 'f', -1, 64)
+		if !strings.HasPrefix(string(out[dtr:]), `"dtr":[`+want+",") {
+			t.Errorf("got\n%s", out[dtr:])
+		}
+
+		// a requested column missing from the result
+		delete(result.Columns, "tmin")
+		if _, err := result.ToJson5(); err == nil {
+			t.Error("no error for the missing column")
+		}
+	})
+}
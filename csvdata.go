@@ -1,11 +1,11 @@
 package csvdata
 
 import (
-	"encoding/csv"
 	"fmt"
 	"math"
-	"os"
-	"strconv"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -31,24 +31,33 @@ func findString(slice []string, val string) int {
 
 type CsvAggregatePointConfigs struct {
 	FileConfig
-	Requests      []RequestColumn
-	TimeOffset    string
-	TimeOffsetDur time.Duration
-	TimeOffsetEp  int64
-	StartTime     time.Time
-	EndTime       time.Time
-	TimePrecision string
+	FileConfigs    []FileConfig // several sources like CsvAggregateTableConfigs, the embedded FileConfig is ignored when set
+	Requests       []RequestColumn
+	TimeOffset     string
+	TimeOffsetDur  time.Duration
+	TimeOffsetEp   int64
+	StartTime      time.Time
+	EndTime        time.Time
+	TimePrecision  string
+	MaxConcurrency int // files read at the same time, zero means one
 }
 
 type RequestColumn struct {
 	InputColumnName  string
+	InputAliases     []string // other names of the input column, the first name found in the header of a file is read
+	Source           string   // Name of the FileConfig the input column is read from, empty means every source
 	OutputColumnName string
 	Method           string
 	PickTime         time.Time
+	PickMode         string // NEAREST, PREVIOUS, NEXT or LINEAR, empty means NEAREST
+	PickTolerance    string // maximum distance to a picked sample, empty means no limit
+	PickToleranceEp  int64
 }
 
 type RequestColumnTable struct {
 	InputColumnName  string
+	InputAliases     []string // other names of the input column, the first name found in the header of a file is read
+	Source           string   // Name of the FileConfig the input column is read from, empty means every source
 	OutputColumnName string
 	Method           string
 	WindowString     string
@@ -56,12 +65,190 @@ type RequestColumnTable struct {
 	PickRelative     string
 	PickEp           int64
 	PickTime         time.Time
+	PickMode         string // NEAREST, PREVIOUS, NEXT or LINEAR, empty means NEAREST
+	PickTolerance    string // maximum distance to a picked sample, empty means half of AggWindow
+	PickToleranceEp  int64
+	FillMethod       string // NONE, PREVIOUS, NEXT, LINEAR or NEAREST, fills the empty results on the grid
+	FillLimit        string // maximum distance to an observed result used to fill, empty means no limit
+	FillLimitEp      int64
 }
 
 type FileConfig struct {
+	Name             string // source name, a request reads only this source with Source or with an input column written as Name.column
 	FileNamingFormat string
+	Station          string // replaces {station} in the file names, after the time formatting so the station is not read as a layout
 	FileFrequency    string
 	FileFrequencyDur time.Duration
+	Sorted           bool // the rows are sorted by time, so the reading starts with a binary search and stops after the end time
+	BuildIndex       bool // build the index of a file when it is missing or out of date, an up to date index is always used
+
+	// discovery mode, used instead of FileNamingFormat when FileGlob is set. The files matching FileGlob are listed
+	// and their time is parsed from the file name with FileTimeLayout, a file holds the rows from its time to the
+	// time of the next file, the last one up to the end of its FileFrequency period. Several files may have the same period.
+	FileGlob       string // pattern of filepath.Glob, {station} is replaced by Station
+	FileTimeLayout string // time layout of the file time, parsed from the base name or from the first group of FileTimeRegexp
+	FileTimeRegexp string // extracts the file time from the base name, empty means the whole base name
+	fileTimeRe     *regexp.Regexp
+
+	// layouts that changed over time, used instead of the layout above when set
+	Periods []FilePeriod
+	periods []FileConfig // the file config of every period, set by check
+}
+
+// FilePeriod is the layout of the files of a FileConfig from From to Until, the fields are like the ones of FileConfig.
+// Only the rows from From to Until are read from its files, so a file may also hold rows of another period.
+type FilePeriod struct {
+	From             time.Time // zero means since always
+	Until            time.Time // excluded, zero means until now
+	FileNamingFormat string
+	FileFrequency    string
+	FileGlob         string
+	FileTimeLayout   string
+	FileTimeRegexp   string
+	Columns          map[string]string // name of an input column in the files of the period, tried before the input column and its aliases
+}
+
+// check checks the file frequency and the discovery mode of a file config, or of every period
+func (filec *FileConfig) check() error {
+	var err error
+	if len(filec.Periods) > 0 {
+		return filec.checkPeriods()
+	}
+	if filec.FileGlob != "" {
+		if filec.FileTimeLayout == "" {
+			return fmt.Errorf("FileTimeLayout is needed with FileGlob %s", filec.FileGlob)
+		}
+		if _, err := filepath.Match(filec.FileGlob, ""); err != nil {
+			return fmt.Errorf("FileGlob %s is not valid", filec.FileGlob)
+		}
+		filec.fileTimeRe = nil
+		if filec.FileTimeRegexp != "" {
+			filec.fileTimeRe, err = regexp.Compile(filec.FileTimeRegexp)
+			if err != nil {
+				return fmt.Errorf("FileTimeRegexp %s is not valid: %v", filec.FileTimeRegexp, err)
+			}
+			if filec.fileTimeRe.NumSubexp() < 1 {
+				return fmt.Errorf("FileTimeRegexp %s has no group", filec.FileTimeRegexp)
+			}
+		}
+	}
+	if !StringInSlice(filec.FileFrequency, []string{"1y", "1M", "7d", "2d", "1d", "24h", "12h", "6h", "3h", "1h", "15m", "10m", "5m", "1m"}) {
+		return fmt.Errorf("FileFrequency must be \"1y\", \"1M\", \"7d\", \"2d\", \"1d\", \"24h\", \"12h\", \"6h\", \"3h\", \"1h\", \"15m\", \"10m\", \"5m\", \"1m\"")
+	}
+	filec.FileFrequencyDur, err = time.ParseDuration(filec.FileFrequency)
+	if err != nil {
+		return fmt.Errorf("FileFrequency %s is not valid", filec.FileFrequency)
+	}
+	// check if the file frequency is zero
+	if filec.FileFrequencyDur == 0 {
+		return fmt.Errorf("FileFrequency %s is zero", filec.FileFrequency)
+	}
+	return nil
+}
+
+// checkPeriods checks the periods, they must be in time order and must not overlap
+func (filec *FileConfig) checkPeriods() error {
+	filec.periods = make([]FileConfig, len(filec.Periods))
+	for i, period := range filec.Periods {
+		if !period.Until.IsZero() && !period.Until.After(period.From) {
+			return fmt.Errorf("period %d ends at %s, before it starts at %s", i, period.Until, period.From)
+		}
+		if i > 0 {
+			prev := filec.Periods[i-1]
+			if prev.Until.IsZero() || period.From.IsZero() || period.From.Before(prev.Until) {
+				return fmt.Errorf("period %d overlaps the period before", i)
+			}
+		}
+
+		// the period is read like a file config with its own layout
+		pfilec := *filec
+		pfilec.FileNamingFormat = period.FileNamingFormat
+		pfilec.FileFrequency = period.FileFrequency
+		pfilec.FileGlob = period.FileGlob
+		pfilec.FileTimeLayout = period.FileTimeLayout
+		pfilec.FileTimeRegexp = period.FileTimeRegexp
+		pfilec.Periods = nil
+		pfilec.periods = nil
+		if err := pfilec.check(); err != nil {
+			return fmt.Errorf("period %d: %v", i, err)
+		}
+		filec.periods[i] = pfilec
+	}
+	return nil
+}
+
+// dataFile is a file of a file config, start is the start of the period it holds
+type dataFile struct {
+	name    string
+	start   time.Time
+	columns map[string]string // the Columns of its FilePeriod
+	from    time.Time         // the From of its FilePeriod, the rows before it belong to the period before
+	until   time.Time         // the Until of its FilePeriod, the rows from it belong to the period after
+}
+
+// periodEpochs returns the range of the epochs of the rows of the file, from included and until excluded.
+// A file without a FilePeriod has no limit.
+func (file dataFile) periodEpochs(timePrecision string) (int64, int64) {
+	var from, until int64 = math.MinInt64, math.MaxInt64
+	if !file.from.IsZero() {
+		from = TimetoEpoch(file.from, timePrecision)
+	}
+	if !file.until.IsZero() {
+		until = TimetoEpoch(file.until, timePrecision)
+	}
+	return from, until
+}
+
+// periodFiles returns the files of every period holding the rows from startUTC to endUTC, in time order
+func (filec *FileConfig) periodFiles(startUTC time.Time, endUTC time.Time) []dataFile {
+	files := []dataFile{}
+	for i, period := range filec.Periods {
+		// the part of the range in the period
+		start, end := startUTC, endUTC
+		if start.Before(period.From) {
+			start = period.From
+		}
+		if !period.Until.IsZero() && !end.Before(period.Until) {
+			end = period.Until.Add(-time.Nanosecond)
+		}
+		if end.Before(start) {
+			continue
+		}
+		for _, file := range filec.periods[i].files(start, end) {
+			file.columns = period.Columns
+			file.from, file.until = period.From, period.Until
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// fileName returns the name of the file of the period starting at d
+func (filec *FileConfig) fileName(d time.Time) string {
+	name := d.Format(filec.FileNamingFormat)
+	if filec.Station != "" {
+		name = strings.ReplaceAll(name, StationPlaceholder, filec.Station)
+	}
+	return name
+}
+
+// files returns the files holding the rows from startUTC to endUTC, in time order
+func (filec *FileConfig) files(startUTC time.Time, endUTC time.Time) []dataFile {
+	if len(filec.periods) > 0 {
+		return filec.periodFiles(startUTC, endUTC)
+	}
+	if filec.FileGlob != "" {
+		return filec.discoverFiles(startUTC, endUTC)
+	}
+
+	startDateFile := GetNearestPastTimeUnit(startUTC, filec.FileFrequency)
+	endDateFile := GetNearestPastTimeUnit(endUTC, filec.FileFrequency).Add(filec.FileFrequencyDur)
+
+	files := []dataFile{}
+	for d := startDateFile; d.Before(endDateFile); d = d.Add(filec.FileFrequencyDur) {
+		files = append(files, dataFile{name: filec.fileName(d), start: d})
+	}
+	return files
 }
 
 type CsvAggregateTableConfigs struct {
@@ -73,9 +260,12 @@ type CsvAggregateTableConfigs struct {
 	StartTime     time.Time
 	EndTime       time.Time
 	TimePrecision string
-	AggWindow     string
+	AggWindow     string // the step between two output rows
 	AggWindowDur  time.Duration
 	AggWindowEp   int64
+	AggLength     string // the default window length, it may be longer than AggWindow for rolling windows, empty means AggWindow
+	AggLengthEp   int64
+	Computed      []RequestComputedColumn // computed after the aggregation, in order
 }
 
 // function to check if string inside []string
@@ -92,12 +282,15 @@ func StringInSlice(a string, list []string) bool {
 func (cfg *CsvAggregatePointConfigs) Check() error {
 	var err error
 
-	if !StringInSlice(cfg.FileFrequency, []string{"1y", "1M", "7d", "2d", "1d", "24h", "12h", "6h", "3h", "1h", "15m", "10m", "5m", "1m"}) {
-		return fmt.Errorf("FileFrequency must be \"1y\", \"1M\", \"7d\", \"2d\", \"1d\", \"24h\", \"12h\", \"6h\", \"3h\", \"1h\", \"15m\", \"10m\", \"5m\", \"1m\"")
-	} else {
-		cfg.FileFrequencyDur, err = time.ParseDuration(cfg.FileFrequency)
-		if err != nil {
-			return fmt.Errorf("FileFrequency %s is not valid", cfg.FileFrequency)
+	// check for file configs, the embedded one is only used when there is no FileConfigs
+	if len(cfg.FileConfigs) == 0 {
+		if err := cfg.FileConfig.check(); err != nil {
+			return err
+		}
+	}
+	for i := range cfg.FileConfigs {
+		if err := cfg.FileConfigs[i].check(); err != nil {
+			return err
 		}
 	}
 
@@ -106,6 +299,10 @@ func (cfg *CsvAggregatePointConfigs) Check() error {
 		return fmt.Errorf("start time %s is after end time %s", cfg.StartTime, cfg.EndTime)
 	}
 
+	if cfg.MaxConcurrency < 0 {
+		return fmt.Errorf("MaxConcurrency %d is negative", cfg.MaxConcurrency)
+	}
+
 	// check if cfg.TimePrecision is valid
 	switch cfg.TimePrecision {
 	case SECOND:
@@ -129,7 +326,34 @@ func (cfg *CsvAggregatePointConfigs) Check() error {
 		}
 	}
 
-	return nil
+	// check for requests
+	for i := range cfg.Requests {
+		req := &cfg.Requests[i]
+		if req.Method == PICK {
+			req.PickToleranceEp, err = checkPick(&req.PickMode, req.PickTolerance, cfg.TimePrecision)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// check the source of every input column
+	inputs := make([]sourcedInput, len(cfg.Requests))
+	for i := range cfg.Requests {
+		inputs[i] = sourcedInput{source: &cfg.Requests[i].Source, column: &cfg.Requests[i].InputColumnName, aliases: cfg.Requests[i].InputAliases}
+	}
+	if err := checkSources(cfg.sources(), inputs); err != nil {
+		return err
+	}
+	return checkHeaders(cfg.sources(), inputs, cfg.StartTime.Add(-cfg.TimeOffsetDur), cfg.EndTime.Add(-cfg.TimeOffsetDur))
+}
+
+// sources returns the file configs read by the point aggregation
+func (cfg *CsvAggregatePointConfigs) sources() []FileConfig {
+	if len(cfg.FileConfigs) == 0 {
+		return []FileConfig{cfg.FileConfig}
+	}
+	return cfg.FileConfigs
 }
 
 // cheker function to check if the configs are valid
@@ -138,18 +362,8 @@ func (cfg *CsvAggregateTableConfigs) Check() error {
 
 	// check for file configs
 	for i := range cfg.FileConfigs {
-		file := &cfg.FileConfigs[i]
-		if !StringInSlice(file.FileFrequency, []string{"1y", "1M", "7d", "2d", "1d", "24h", "12h", "6h", "3h", "1h", "15m", "10m", "5m", "1m"}) {
-			return fmt.Errorf("FileFrequency must be \"1y\", \"1M\", \"7d\", \"2d\", \"1d\", \"24h\", \"12h\", \"6h\", \"3h\", \"1h\", \"15m\", \"10m\", \"5m\", \"1m\"")
-		} else {
-			file.FileFrequencyDur, err = time.ParseDuration(file.FileFrequency)
-			if err != nil {
-				return fmt.Errorf("FileFrequency %s is not valid", file.FileFrequency)
-			}
-			// check if the file frequency is zero
-			if file.FileFrequencyDur == 0 {
-				return fmt.Errorf("FileFrequency %s is zero", file.FileFrequency)
-			}
+		if err := cfg.FileConfigs[i].check(); err != nil {
+			return err
 		}
 	}
 
@@ -191,6 +405,22 @@ func (cfg *CsvAggregateTableConfigs) Check() error {
 	if err != nil {
 		return fmt.Errorf("AggWindow window %s is not valid", cfg.AggWindow)
 	}
+	if cfg.AggWindowEp <= 0 {
+		return fmt.Errorf("AggWindow %s must be positive", cfg.AggWindow)
+	}
+
+	// check if cfg.AggLength is valid
+	if cfg.AggLength == "" {
+		cfg.AggLengthEp = cfg.AggWindowEp
+	} else {
+		cfg.AggLengthEp, err = DurationtoEpoch(cfg.AggLength, cfg.TimePrecision)
+		if err != nil {
+			return fmt.Errorf("AggLength %s is not valid", cfg.AggLength)
+		}
+		if cfg.AggLengthEp <= 0 {
+			return fmt.Errorf("AggLength %s must be positive", cfg.AggLength)
+		}
+	}
 
 	// check for requests
 	for i := range cfg.Requests {
@@ -204,9 +434,14 @@ func (cfg *CsvAggregateTableConfigs) Check() error {
 			req.OutputColumnName = req.InputColumnName
 		}
 		// check if the method is valid
-		if !StringInSlice(req.Method, []string{SUM, COUNT, MEAN, MAX, MIN, FIRST, LAST, PICK}) {
+		if !StringInSlice(req.Method, []string{SUM, COUNT, MEAN, MAX, MIN, FIRST, LAST, PICK, RESAMPLE}) {
 			return fmt.Errorf("method %s is not valid", req.Method)
 		}
+		// check the fill method and limit
+		req.FillLimitEp, err = checkFill(req.FillMethod, req.FillLimit, cfg.TimePrecision)
+		if err != nil {
+			return err
+		}
 
 		if req.Method == PICK {
 
@@ -226,6 +461,15 @@ func (cfg *CsvAggregateTableConfigs) Check() error {
 				return fmt.Errorf("pick relative %s is greater than aggwindow %s", req.PickRelative, cfg.AggWindow)
 			}
 
+			// check the pick mode and tolerance, no tolerance means half of the aggwindow
+			req.PickToleranceEp, err = checkPick(&req.PickMode, req.PickTolerance, cfg.TimePrecision)
+			if err != nil {
+				return err
+			}
+			if req.PickToleranceEp == 0 {
+				req.PickToleranceEp = (cfg.AggWindowEp + 1) / 2
+			}
+
 		} else {
 			if req.WindowString != "" {
 				// Assuming the WindowString is of the form like -23h59m59s_0h, you should parse it like so
@@ -244,34 +488,58 @@ func (cfg *CsvAggregateTableConfigs) Check() error {
 					return fmt.Errorf("end duration parse error: %v", err)
 				}
 
-				// Here you calculate the total duration and check if it's more than aggwindow
-				if end-start > cfg.AggWindowEp {
-					return fmt.Errorf("the total duration exceeds the limit")
+				// the window may be longer than aggwindow, the windows will overlap
+				if end < start {
+					return fmt.Errorf("window string %s end is before start", req.WindowString)
 				}
 
 				// store the window
 				req.WindowEp = [2]int64{start, end}
-			} else {
-				// window -cfg.AggWindowEp + 1 to 0
-				start := -cfg.AggWindowEp + 1
+			} else if req.Method == RESAMPLE {
+				// window centered on the result time
+				start := -cfg.AggLengthEp / 2
 
-				// Here you calculate the total duration and check if it's more than aggwindow
-				if 0-start > cfg.AggWindowEp {
-					return fmt.Errorf("the total duration exceeds the limit")
-				}
+				req.WindowEp = [2]int64{start, start + cfg.AggLengthEp - 1}
+			} else {
+				// window -cfg.AggLengthEp + 1 to 0
+				start := -cfg.AggLengthEp + 1
 
 				req.WindowEp = [2]int64{start, 0}
 			}
 		}
 	}
 
-	return nil
+	// check for computed requests
+	known := make([]string, 0, len(cfg.Requests)+len(cfg.Computed))
+	for _, req := range cfg.Requests {
+		known = append(known, req.OutputColumnName)
+	}
+	for i := range cfg.Computed {
+		if err := cfg.Computed[i].check(known); err != nil {
+			return err
+		}
+		known = append(known, cfg.Computed[i].OutputColumnName)
+	}
+
+	// check the source of every input column
+	inputs := make([]sourcedInput, len(cfg.Requests))
+	for i := range cfg.Requests {
+		inputs[i] = sourcedInput{source: &cfg.Requests[i].Source, column: &cfg.Requests[i].InputColumnName, aliases: cfg.Requests[i].InputAliases}
+	}
+	if err := checkSources(cfg.FileConfigs, inputs); err != nil {
+		return err
+	}
+	lowestWindowRelative, highestWindowRelative := cfg.readRange()
+	startTimeREADUTC := cfg.StartTime.Add(-cfg.TimeOffsetDur).Add(EpochToDuration(lowestWindowRelative, cfg.TimePrecision))
+	endTimeREADUTC := cfg.EndTime.Add(-cfg.TimeOffsetDur).Add(EpochToDuration(highestWindowRelative, cfg.TimePrecision))
+	return checkHeaders(cfg.FileConfigs, inputs, startTimeREADUTC, endTimeREADUTC)
 }
 
-// CsvAggregateTable aggregates a table of data
+// CsvAggregateTable aggregates a table of data. The files are not sorted whole, their rows are put in
+// time order in runs of a few thousand rows, so the rows of a file far out of order are dropped and
+// reported by DroppedRows.
 func CsvAggregateTable(cfg CsvAggregateTableConfigs) (SAResult, error) {
 	var wg sync.WaitGroup
-	var wgfile sync.WaitGroup
 
 	// check if configs are valid
 	err := cfg.Check()
@@ -304,22 +572,248 @@ func CsvAggregateTable(cfg CsvAggregateTableConfigs) (SAResult, error) {
 		col := SAColumn{
 			OutputColumnName: req.OutputColumnName,
 			WindowRelativeEp: req.WindowEp,
+			PickRelativeEp:   req.PickEp,
+			PickMode:         req.PickMode,
+			PickToleranceEp:  req.PickToleranceEp,
+			FillMethod:       req.FillMethod,
+			FillLimitEp:      req.FillLimitEp,
 			TimeResultEp:     &epochlist,
 			Result:           make([]float64, len(epochlist)),
 		}
-		samap[req.OutputColumnName] = NewSmartAggregator(req.Method, &col, &wg)
+		samap[req.OutputColumnName] = NewSmartAggregatorBatch(req.Method, &col, &wg)
 	}
 
-	// get the lowest window relative and highest window relative
+	// read the files and aggregate
+	report, err := cfg.feedTable(samap, nil, false)
+	cfg.closeBatches(samap)
+	// wait for all the aggregator to finish
+	wg.Wait()
+	if err != nil {
+		return SAResult{}, err
+	}
+
+	// generate SAOutput
+	sares := samap.SAMapToStruct(cfg.TimePrecision)
+	sares.Requests = &cfg.Requests
+	sares.UnsortedFiles = report.unsorted
+	sares.DroppedRows = report.dropped
+
+	// second stage, computed columns
+	if len(cfg.Computed) > 0 {
+		if err := sares.Compute(cfg.Computed); err != nil {
+			return SAResult{}, err
+		}
+	}
+
+	return sares, nil
+}
+
+// PointResult is the detailed result of one request of CsvAggregatePointDetail
+type PointResult struct {
+	Value    float64
+	PickTime time.Time // time of the picked sample in UTC, like RequestColumn.PickTime, only for PICK
+	Picked   bool      // false when no sample is within the pick tolerance, only for PICK
+}
+
+// CsvAggregatePoint aggregates a single point in time
+func CsvAggregatePoint(cfg CsvAggregatePointConfigs) (map[string]float64, error) {
+	detail, err := CsvAggregatePointDetail(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	retmap := make(map[string]float64, len(detail))
+	for key, res := range detail {
+		retmap[key] = res.Value
+	}
+	return retmap, nil
+}
+
+// CsvAggregatePointDetail aggregates a single point in time, and also returns the time of the picked samples
+func CsvAggregatePointDetail(cfg CsvAggregatePointConfigs) (map[string]PointResult, error) {
+
+	// check if configs are valid
+	err := cfg.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	// startTimeUTC os the start time in UTC, Starttime minus offset
+	startTimeUTC := cfg.StartTime.Add(-cfg.TimeOffsetDur)
+	endTimeUTC := cfg.EndTime.Add(-cfg.TimeOffsetDur)
+
+	startTimeEpoch := TimetoEpoch(cfg.StartTime, cfg.TimePrecision)
+	endTimeEpoch := TimetoEpoch(cfg.EndTime, cfg.TimePrecision)
+
+	// get the list of files of every source, ordered by the start of their period then by source,
+	// so a column found in several sources reaches its aggregator in a fixed order
+	sources := cfg.sources()
+	type sourceFile struct {
+		dataFile
+		source int
+	}
+	files := []sourceFile{}
+	for s := range sources {
+		for _, file := range sources[s].files(startTimeUTC, endTimeUTC) {
+			files = append(files, sourceFile{dataFile: file, source: s})
+		}
+	}
+	sort.SliceStable(files, func(a, b int) bool {
+		return files[a].start.Before(files[b].start)
+	})
+
+	// prepare for aggregation
+	retmap := make(map[string]PointResult, len(cfg.Requests))
+	inputs := make([]requestInput, len(cfg.Requests))
+	aggmap := make(map[string]*Aggregator, len(cfg.Requests))
+	for i, req := range cfg.Requests {
+		inputs[i] = requestInput{column: req.InputColumnName, aliases: req.InputAliases, source: req.Source}
+		aggmap[req.OutputColumnName] = NewAggregator(req.Method)
+		if req.Method == PICK {
+			pickTimeEp := TimetoEpoch(req.PickTime, cfg.TimePrecision)
+			pickTimeEp += cfg.TimeOffsetEp
+			aggmap[req.OutputColumnName].PickerDate = &PickerDate{PickEpoch: pickTimeEp, PickMode: req.PickMode, PickToleranceEp: req.PickToleranceEp}
+		}
+	}
+
+	owners := newSourceOwners(sources, inputs)
+
+	// readFile reads the samples of every request in one file of the source s
+	readFile := func(file dataFile, s int) [][]Input {
+		samples := make([][]Input, len(cfg.Requests))
+		filec := sources[s]
+		if owners.failed() != nil {
+			return samples
+		}
+
+		// read the file and get the column name
+		csvfile, reader, csvColNames, err := openCSV(file.name, filec, startTimeEpoch-cfg.TimeOffsetEp, endTimeEpoch-cfg.TimeOffsetEp)
+		if err != nil {
+			return samples
+		}
+		defer csvfile.Close()
+		columns := file.fileColumns(csvColNames, inputs, filec.Name)
+		if owners.claim(s, columns) != nil {
+			return samples
+		}
+		decoder := newFieldDecoder(reader.projectColumns(csvColNames, columns))
+		fromEp, untilEp := file.periodEpochs(cfg.TimePrecision)
+
+		// loop through the file
+		for {
+			// read the line
+			line, err := reader.Read()
+			if err != nil {
+				return samples
+			}
+
+			// convert date
+			epochiter, ok := parseEpochBytes(line[0])
+			if !ok {
+				continue
+			}
+
+			// the rows out of the period of the file are read from the files of the other periods
+			if epochiter < fromEp || epochiter >= untilEp {
+				if filec.Sorted && epochiter >= untilEp {
+					return samples
+				}
+				continue
+			}
+
+			// add offset
+			epochiter += cfg.TimeOffsetEp
+
+			// check if the epoch is within
+			if !IsBetween(startTimeEpoch, endTimeEpoch, epochiter) {
+				// the rest of a sorted file is after the end time
+				if filec.Sorted && epochiter > endTimeEpoch {
+					return samples
+				}
+				continue
+			}
+
+			// every column is parsed once for all the requests
+			decoder.decode(line)
+			for i := range cfg.Requests {
+				dataiter, ok := decoder.value(i)
+				if !ok {
+					continue
+				}
+				samples[i] = append(samples[i], Input{Epoch: epochiter, Value: dataiter})
+			}
+		}
+	}
+
+	// read up to MaxConcurrency files at the same time, a file is only started when
+	// the file MaxConcurrency before it is aggregated, so the memory stays bounded
+	maxConcurrency := cfg.MaxConcurrency
+	if maxConcurrency <= 0 {
+		maxConcurrency = 1
+	}
+	sem := make(chan struct{}, maxConcurrency)
+	fileSamples := make([]chan [][]Input, len(files))
+	for i := range fileSamples {
+		fileSamples[i] = make(chan [][]Input, 1)
+	}
+	go func() {
+		for i, file := range files {
+			sem <- struct{}{}
+			go func(i int, file sourceFile) {
+				fileSamples[i] <- readFile(file.dataFile, file.source)
+			}(i, file)
+		}
+	}()
+
+	// aggregate the files in order, so the samples reach the aggregators in the same order as a sequential read
+	for i := range files {
+		samples := <-fileSamples[i]
+		for j, req := range cfg.Requests {
+			for _, in := range samples[j] {
+				aggmap[req.OutputColumnName].Data <- in
+			}
+		}
+		<-sem
+	}
+
+	// work done close all the aggregator
+	for _, req := range cfg.Requests {
+		close(aggmap[req.OutputColumnName].Data)
+	}
+
+	// get the result
+	for _, req := range cfg.Requests {
+		result := <-aggmap[req.OutputColumnName].Done
+		res := PointResult{Value: result.Value}
+		if req.Method == PICK {
+			res.Picked = result.Ok
+			if result.Ok {
+				// the epochs are shifted by the offset, the pick time is in UTC like the requested one
+				res.PickTime = EpochtoTime(result.Epoch-cfg.TimeOffsetEp, cfg.TimePrecision).UTC()
+			}
+		}
+		retmap[req.OutputColumnName] = res
+	}
+	if err := owners.failed(); err != nil {
+		return nil, err
+	}
+
+	return retmap, nil
+}
+
+// readRange returns the lowest and the highest epoch read around a result time
+func (cfg *CsvAggregateTableConfigs) readRange() (int64, int64) {
 	var lowestWindowRelative int64 = math.MaxInt64
 	var highestWindowRelative int64 = math.MinInt64
 	for _, req := range cfg.Requests {
 		if req.Method == PICK {
-			if req.PickEp < lowestWindowRelative {
-				lowestWindowRelative = req.PickEp
+			// read the samples around the pick, up to the tolerance
+			tolerance := req.PickToleranceEp
+			if req.PickEp-tolerance < lowestWindowRelative {
+				lowestWindowRelative = req.PickEp - tolerance
 			}
-			if req.PickEp > highestWindowRelative {
-				highestWindowRelative = req.PickEp
+			if req.PickEp+tolerance > highestWindowRelative {
+				highestWindowRelative = req.PickEp + tolerance
 			}
 		} else {
 			if req.WindowEp[0] < lowestWindowRelative {
@@ -330,6 +824,24 @@ func CsvAggregateTable(cfg CsvAggregateTableConfigs) (SAResult, error) {
 			}
 		}
 	}
+	return lowestWindowRelative, highestWindowRelative
+}
+
+// feedTable reads the files of every FileConfig and sends the values to the aggregators.
+// The rows of every file are sorted and the sources are merged, so every aggregator receives its values in
+// time order. The files of a source may overlap, their rows are merged in order, see sourceOrder.
+// Reading stops early when stop is closed, or with an error when an input without a source is found in
+// several sources. The files are sorted in runs of runRows rows, so the rows of a file far out of order are
+// dropped. With ticks, for the stream, a missing or invalid value is sent as a tick so the aggregator knows
+// the time went on.
+func (cfg *CsvAggregateTableConfigs) feedTable(samap SAMap, stop <-chan struct{}, ticks bool) (feedReport, error) {
+	var wgfile sync.WaitGroup
+
+	startTimeEpoch := TimetoEpoch(cfg.StartTime, cfg.TimePrecision)
+	endTimeEpoch := TimetoEpoch(cfg.EndTime, cfg.TimePrecision)
+
+	// get the lowest window relative and highest window relative
+	lowestWindowRelative, highestWindowRelative := cfg.readRange()
 	// convert lowestWindowRelative and highestWindowRelative to duration
 	lowestWindowRelativeDur := EpochToDuration(lowestWindowRelative, cfg.TimePrecision)
 	highestWindowRelativeDur := EpochToDuration(highestWindowRelative, cfg.TimePrecision)
@@ -337,66 +849,79 @@ func CsvAggregateTable(cfg CsvAggregateTableConfigs) (SAResult, error) {
 	startREADEpoch := startTimeEpoch + lowestWindowRelative
 	endREADEpoch := endTimeEpoch + highestWindowRelative
 
-	fileproc := func(filec FileConfig) {
+	// the input column of every request, its aliases and its source
+	inputs := make([]requestInput, len(cfg.Requests))
+	for i, req := range cfg.Requests {
+		inputs[i] = requestInput{column: req.InputColumnName, aliases: req.InputAliases, source: req.Source}
+	}
+
+	owners := newSourceOwners(cfg.FileConfigs, inputs)
+
+	// the aggregator of every request
+	aggs := make([]*SmartAggregator, len(cfg.Requests))
+	for i, req := range cfg.Requests {
+		aggs[i] = samap[req.OutputColumnName]
+	}
+
+	// the runs of every source, with its unsorted files and the rows of its files that could not be placed
+	sources := make([]*sourceRuns, len(cfg.FileConfigs))
+
+	// fileproc reads the files of the source s, and sends their rows in time order with runs
+	fileproc := func(s int, filec FileConfig, runs *sourceRuns) {
 		defer wgfile.Done()
-		coli := make(map[string]int, len(cfg.Requests))
+		defer close(runs.files)
 
 		// startTimeUTC os the start time in UTC, Starttime minus offset, and minus lowestWindowRelativeDur
 		startTimeREADUTC := cfg.StartTime.Add(-cfg.TimeOffsetDur).Add(lowestWindowRelativeDur)
-		startDateFile := GetNearestPastTimeUnit(startTimeREADUTC, filec.FileFrequency)
 		endTimeREADUTC := cfg.EndTime.Add(-cfg.TimeOffsetDur).Add(highestWindowRelativeDur)
-		endDateFile := GetNearestPastTimeUnit(endTimeREADUTC, filec.FileFrequency).Add(time.Duration(filec.FileFrequencyDur))
-
-		// get the list of files dates
-		fdates := []time.Time{}
-		for d := startDateFile; d.Before(endDateFile); d = d.Add(time.Duration(filec.FileFrequencyDur)) {
-			fdates = append(fdates, d)
-		}
 
-		// loop through the fdates
-		for _, day := range fdates {
-			func() {
-				// file name for the day
-				filename := day.Format(filec.FileNamingFormat)
-
-				// read the file
-				csvfile, err := os.Open(filename)
-				if err != nil {
-					csvfile.Close()
-					return
+		// loop through the files
+		for _, file := range filec.files(startTimeREADUTC, endTimeREADUTC) {
+			rows, stopped := func() (*fileRows, bool) {
+				rows := newFileRows(len(cfg.Requests))
+				if owners.failed() != nil {
+					return nil, true
 				}
-				defer csvfile.Close()
 
-				// read the file
-				reader := csv.NewReader(csvfile)
-
-				// get the column name
-				csvColNames, err := reader.Read()
+				// read the file and get the column name
+				csvfile, reader, csvColNames, err := openCSV(file.name, filec, startREADEpoch-cfg.TimeOffsetEp, endREADEpoch-cfg.TimeOffsetEp)
 				if err != nil {
-					csvfile.Close()
-					return
+					return rows, false
 				}
-				for _, req := range cfg.Requests {
-					colfind := findString(csvColNames, req.InputColumnName)
-					if colfind == -1 {
-						continue
-					}
-					coli[req.InputColumnName] = colfind
+				defer csvfile.Close()
+				columns := file.fileColumns(csvColNames, inputs, filec.Name)
+				if owners.claim(s, columns) != nil {
+					return nil, true
 				}
+				decoder := newFieldDecoder(reader.projectColumns(csvColNames, columns))
+				fromEp, untilEp := file.periodEpochs(cfg.TimePrecision)
 
 				// loop through the file
-			readloop:
 				for {
+					select {
+					case <-stop:
+						return nil, true
+					default:
+					}
+
 					// read the line
 					line, err := reader.Read()
 					if err != nil {
-						break readloop
+						return rows, false
 					}
 
 					// convert date
-					epochiter, err := strconv.ParseInt(line[0], 10, 64)
-					if err != nil {
-						continue readloop
+					epochiter, ok := parseEpochBytes(line[0])
+					if !ok {
+						continue
+					}
+
+					// the rows out of the period of the file are read from the files of the other periods
+					if epochiter < fromEp || epochiter >= untilEp {
+						if filec.Sorted && epochiter >= untilEp {
+							return rows, false
+						}
+						continue
 					}
 
 					// add offset
@@ -404,169 +929,71 @@ func CsvAggregateTable(cfg CsvAggregateTableConfigs) (SAResult, error) {
 
 					// check if the epoch is within
 					if !IsBetween(startREADEpoch, endREADEpoch, epochiter) {
-						// check if the epoch is after the endREADEpoch
-						if epochiter > endREADEpoch {
-							break readloop
+						// the rest of a sorted file is after the end time
+						if filec.Sorted && epochiter > endREADEpoch {
+							return rows, false
 						}
-						continue readloop
+						continue
 					}
 
-					// aggregate
-				reqloop:
-					for _, req := range cfg.Requests {
-						inpcolname := req.InputColumnName
-						colidx, ok := coli[inpcolname]
-						if !ok {
-							continue reqloop
-						}
-						datastr := line[colidx]
-						dataiter, err := strconv.ParseFloat(datastr, 64)
-						if err != nil {
-							continue reqloop
+					// every column is parsed once for all the requests
+					decoder.decode(line)
+					rows.add(epochiter, decoder)
+					if rows.len() >= runRows {
+						if !runs.put(file.name, rows) {
+							return nil, true
 						}
-						samap[req.OutputColumnName].Data <- Input{Epoch: epochiter, Value: dataiter}
+						rows = newFileRows(len(cfg.Requests))
 					}
 				}
-
 			}()
+			if stopped || !runs.put(file.name, rows) {
+				return
+			}
 		}
+		runs.flush()
 	}
 
-	// loop through the file configs
-	for _, filec := range cfg.FileConfigs {
-		// copy the file config
-		filectoproc := filec
+	// read the file configs, each in its own goroutine
+	cursors := make([]*sourceCursor, len(cfg.FileConfigs))
+	for s, filec := range cfg.FileConfigs {
+		files := make(chan *fileRows, 1)
+		cursors[s] = &sourceCursor{files: files}
+		sources[s] = newSourceRuns(files, stop)
 		wgfile.Add(1)
-		go fileproc(filectoproc)
+		go fileproc(s, filec, sources[s])
 	}
 
+	// merge the sources in time order
+	batcher := newInputBatcher(aggs)
+	mergeSources(cursors, batcher, stop, ticks)
+	batcher.flush()
+
 	// wait for all the file reader to finish
 	wgfile.Wait()
-	// work done close all the aggregator
-	for _, req := range cfg.Requests {
-		close(samap[req.OutputColumnName].Data)
-	}
-	// wait for all the aggregator to finish
-	wg.Wait()
-
-	// generate SAOutput
-	sares := samap.SAMapToStruct(cfg.TimePrecision)
-	sares.Requests = &cfg.Requests
-
-	return sares, nil
-}
-
-// CsvAggregatePoint aggregates a single point in time
-func CsvAggregatePoint(cfg CsvAggregatePointConfigs) (map[string]float64, error) {
 
-	// check if configs are valid
-	err := cfg.Check()
-	if err != nil {
-		return nil, err
-	}
-
-	// startTimeUTC os the start time in UTC, Starttime minus offset
-	startTimeUTC := cfg.StartTime.Add(-cfg.TimeOffsetDur)
-	startDateFile := GetNearestPastTimeUnit(startTimeUTC, cfg.FileFrequency)
-	endTimeUTC := cfg.EndTime.Add(-cfg.TimeOffsetDur)
-	endDateFile := GetNearestPastTimeUnit(endTimeUTC, cfg.FileFrequency).Add(time.Duration(cfg.FileFrequencyDur))
-
-	startTimeEpoch := TimetoEpoch(cfg.StartTime, cfg.TimePrecision)
-	endTimeEpoch := TimetoEpoch(cfg.EndTime, cfg.TimePrecision)
-
-	// get the list of files dates
-	fdates := []time.Time{}
-	for d := startDateFile; d.Before(endDateFile); d = d.Add(time.Duration(cfg.FileFrequencyDur)) {
-		fdates = append(fdates, d)
-	}
-
-	// prepare for aggregation
-	retmap := make(map[string]float64, len(cfg.Requests))
-	coli := make(map[string]int, len(cfg.Requests))
-	aggmap := make(map[string]*Aggregator, len(cfg.Requests))
-	for _, req := range cfg.Requests {
-		aggmap[req.OutputColumnName] = NewAggregator(req.Method)
-		if req.Method == PICK {
-			pickTimeEp := TimetoEpoch(req.PickTime, cfg.TimePrecision)
-			pickTimeEp += cfg.TimeOffsetEp
-			aggmap[req.OutputColumnName].PickerDate = &PickerDate{PickEpoch: pickTimeEp}
-		}
-	}
-
-	// loop through the fdates
-	for _, day := range fdates {
-		func() {
-			// file name for the day
-			filename := day.Format(cfg.FileNamingFormat)
-
-			// read the file
-			csvfile, err := os.Open(filename)
-			if err != nil {
-				csvfile.Close()
-				return
+	report := feedReport{unsorted: []string{}}
+	for _, runs := range sources {
+		report.unsorted = append(report.unsorted, runs.unsorted...)
+		for name, n := range runs.dropped {
+			if report.dropped == nil {
+				report.dropped = map[string]int{}
 			}
-			defer csvfile.Close()
-
-			// read the file
-			reader := csv.NewReader(csvfile)
-
-			// get the column name
-			csvColNames, err := reader.Read()
-			if err != nil {
-				csvfile.Close()
-				return
-			}
-			for _, req := range cfg.Requests {
-				coli[req.InputColumnName] = findString(csvColNames, req.InputColumnName)
-			}
-
-			// loop through the file
-			for {
-				// read the line
-				line, err := reader.Read()
-				if err != nil {
-					return
-				}
-
-				// convert date
-				epochiter, err := strconv.ParseInt(line[0], 10, 64)
-				if err != nil {
-					continue
-				}
-
-				// add offset
-				epochiter += cfg.TimeOffsetEp
-
-				// check if the epoch is within
-				if !IsBetween(startTimeEpoch, endTimeEpoch, epochiter) {
-					continue
-				}
-
-				// aggregate
-				for _, req := range cfg.Requests {
-					inpcolname := req.InputColumnName
-					colidx := coli[inpcolname]
-					datastr := line[colidx]
-					dataiter, err := strconv.ParseFloat(datastr, 64)
-					if err != nil {
-						continue
-					}
-					aggmap[req.OutputColumnName].Data <- Input{Epoch: epochiter, Value: dataiter}
-				}
-			}
-		}()
+			report.dropped[name] += n
+		}
 	}
+	return report, owners.failed()
+}
 
-	// work done close all the aggregator
-	for _, req := range cfg.Requests {
-		close(aggmap[req.OutputColumnName].Data)
-	}
+// feedReport is what feedTable found in the files
+type feedReport struct {
+	unsorted []string       // the files whose rows are not in time order
+	dropped  map[string]int // the rows that could not be put in time order by file, they are not aggregated
+}
 
-	// get the result
+// closeBatches closes the aggregators fed by feedTable, once the reading is done
+func (cfg *CsvAggregateTableConfigs) closeBatches(samap SAMap) {
 	for _, req := range cfg.Requests {
-		result := <-aggmap[req.OutputColumnName].Done
-		retmap[req.OutputColumnName] = result.Value
+		close(samap[req.OutputColumnName].Batches)
 	}
-
-	return retmap, nil
 }
@@ -2,7 +2,11 @@ package csvdata_test
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -126,9 +130,10 @@ func TestCsvAggregateTable(t *testing.T) {
 		return
 	}
 
-	result.SaveToCSV("./example/out.csv")
+	out := filepath.Join(fixtureDir(t), "out.csv")
+	result.SaveToCSV(out)
 	// test if file is exist
-	if _, err := os.Stat("./example/out.csv"); os.IsNotExist(err) {
+	if _, err := os.Stat(out); os.IsNotExist(err) {
 		t.Error(err)
 		return
 	}
@@ -164,3 +169,590 @@ func BenchmarkCsvAggregatePoint(b *testing.B) {
 		}
 	}
 }
+
+func TestCsvAggregateTable_Rolling(t *testing.T) {
+	// 3 hour window every hour, compared with a 3 hour window every 3 hours
+	rolling := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint_avg", Method: csvdata.MEAN},
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint_count", Method: csvdata.COUNT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 3, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC),
+		TimePrecision: "second",
+		AggWindow:     "1h",
+		AggLength:     "3h",
+	}
+	stepped := rolling
+	stepped.AggWindow = "3h"
+	stepped.AggLength = ""
+
+	rollres, err := csvdata.CsvAggregateTable(rolling)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stepres, err := csvdata.CsvAggregateTable(stepped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*rollres.TimeStamp) != 10 {
+		t.Fatalf("got %d rows, want 10", len(*rollres.TimeStamp))
+	}
+	for i := range *stepres.TimeStamp {
+		for _, col := range []string{"dewpoint_avg", "dewpoint_count"} {
+			got := (*rollres.Columns[col])[i*3]
+			want := (*stepres.Columns[col])[i]
+			if got != want {
+				t.Errorf("%s row %d got %v, want %v", col, i*3, got, want)
+			}
+		}
+	}
+	// the rows between the stepped rows must be filled too
+	for i, v := range *rollres.Columns["dewpoint_avg"] {
+		if math.IsNaN(v) {
+			t.Errorf("dewpoint_avg row %d is NaN", i)
+		}
+	}
+}
+
+func TestCsvAggregateTable_PickTolerance(t *testing.T) {
+	// the files start at 07:00 local time, the picks before are too far from any sample
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "temperature", Method: csvdata.PICK, PickRelative: "0h"},
+		},
+		StartTime:     time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 8, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		TimeOffset:    "7h",
+		AggWindow:     "1h",
+	}
+	result, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values := *result.Columns["temperature"]
+	if len(values) != 9 {
+		t.Fatalf("got %d rows, want 9", len(values))
+	}
+	for i, v := range values[:7] {
+		if !math.IsNaN(v) {
+			t.Errorf("row %d got %v, want NaN", i, v)
+		}
+	}
+	if values[7] != 25.8 {
+		t.Errorf("row 7 got %v, want 25.8", values[7])
+	}
+}
+
+func TestCsvAggregatePointDetail(t *testing.T) {
+	cfg := csvdata.CsvAggregatePointConfigs{
+		FileConfig: csvdata.FileConfig{
+			FileNamingFormat: "./example/2006-01-02.csv",
+			FileFrequency:    "24h",
+		},
+		Requests: []csvdata.RequestColumn{
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint", Method: csvdata.PICK, PickTime: time.Date(2023, 1, 10, 3, 0, 20, 0, time.UTC), PickTolerance: "1m"},
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint_far", Method: csvdata.PICK, PickTime: time.Date(2023, 1, 10, 23, 30, 0, 0, time.UTC), PickTolerance: "1m"},
+		},
+		TimeOffset:    "0h",
+		StartTime:     time.Date(2023, 1, 10, 1, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC),
+		TimePrecision: "second",
+	}
+
+	agg, err := csvdata.CsvAggregatePointDetail(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !agg["dewpoint"].Picked || agg["dewpoint"].Value != 24.13 {
+		t.Errorf("dewpoint got %+v", agg["dewpoint"])
+	}
+	if want := time.Date(2023, 1, 10, 3, 0, 0, 0, time.UTC); !agg["dewpoint"].PickTime.Equal(want) {
+		t.Errorf("dewpoint pick time got %s, want %s", agg["dewpoint"].PickTime, want)
+	}
+
+	// the pick time is far from the read range
+	if agg["dewpoint_far"].Picked || !math.IsNaN(agg["dewpoint_far"].Value) {
+		t.Errorf("dewpoint_far got %+v", agg["dewpoint_far"])
+	}
+}
+
+func TestCsvAggregatePointDetail_TimeOffset(t *testing.T) {
+	cfg := csvdata.CsvAggregatePointConfigs{
+		FileConfig: csvdata.FileConfig{
+			FileNamingFormat: "./example/2006-01-02.csv",
+			FileFrequency:    "24h",
+		},
+		Requests: []csvdata.RequestColumn{
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint", Method: csvdata.PICK, PickTime: time.Date(2023, 1, 10, 3, 0, 20, 0, time.UTC), PickTolerance: "1m"},
+		},
+		// the same UTC range as TestCsvAggregatePointDetail in local time
+		TimeOffset:    "7h",
+		StartTime:     time.Date(2023, 1, 10, 8, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 19, 0, 0, 0, time.UTC),
+		TimePrecision: "second",
+	}
+
+	agg, err := csvdata.CsvAggregatePointDetail(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !agg["dewpoint"].Picked || agg["dewpoint"].Value != 24.13 {
+		t.Errorf("dewpoint got %+v", agg["dewpoint"])
+	}
+	if want := time.Date(2023, 1, 10, 3, 0, 0, 0, time.UTC); !agg["dewpoint"].PickTime.Equal(want) {
+		t.Errorf("dewpoint pick time got %s, want %s", agg["dewpoint"].PickTime, want)
+	}
+}
+
+func TestCsvAggregateTableStream(t *testing.T) {
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint_avg", Method: csvdata.MEAN},
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint_max", Method: csvdata.MAX, WindowString: "-3h_0h"},
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint_pick", Method: csvdata.PICK, PickRelative: "0h", PickTolerance: "5m"},
+			{InputColumnName: "health_ev_water_level", OutputColumnName: "health_count", Method: csvdata.COUNT},
+		},
+		Computed: []csvdata.RequestComputedColumn{
+			{InputColumnNames: []string{"dewpoint_max", "dewpoint_avg"}, OutputColumnName: "dewpoint_spread", Operator: csvdata.SUBTRACT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 1, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 11, 23, 0, 0, 0, time.UTC),
+		TimePrecision: "second",
+		AggWindow:     "1h",
+	}
+
+	table, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, err := csvdata.CsvAggregateTableStream(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	if want := table.ColumnNames(); strings.Join(stream.Columns, ",") != strings.Join(want, ",") {
+		t.Fatalf("columns got %v, want %v", stream.Columns, want)
+	}
+	i := 0
+	for row := range stream.Rows {
+		if !row.Time.Equal((*table.TimeStamp)[i]) {
+			t.Errorf("row %d time got %s, want %s", i, row.Time, (*table.TimeStamp)[i])
+		}
+		for j, col := range stream.Columns {
+			want := (*table.Columns[col])[i]
+			if got := row.Values[j]; got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+				t.Errorf("row %d %s got %v, want %v", i, col, got, want)
+			}
+		}
+		i++
+	}
+	if i != len(*table.TimeStamp) {
+		t.Errorf("got %d rows, want %d", i, len(*table.TimeStamp))
+	}
+}
+
+func TestCsvAggregateTableStream_Close(t *testing.T) {
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "dewpoint_avg", Method: csvdata.MEAN},
+		},
+		StartTime:     time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 11, 23, 0, 0, 0, time.UTC),
+		TimePrecision: "second",
+		AggWindow:     "10m",
+	}
+
+	stream, err := csvdata.CsvAggregateTableStream(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, ok := <-stream.Rows
+	if !ok || !row.Time.Equal(cfg.StartTime) {
+		t.Fatalf("first row got %+v", row)
+	}
+	// stop after the first row, Close must not block
+	stream.Close()
+	if _, ok := <-stream.Rows; ok {
+		t.Error("rows are not closed")
+	}
+	// the files may still be read after Close
+	stream.UnsortedFiles()
+
+	cfg.Requests[0].FillMethod = csvdata.PREVIOUS
+	if _, err := csvdata.CsvAggregateTableStream(cfg); err == nil {
+		t.Error("expected an error for the fill method")
+	}
+}
+
+func TestCsvAggregateTableStream_MissingColumn(t *testing.T) {
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	dir := writeSecondFile(t, day)
+	// a stray row at the end of the file, it is only found when the whole file is read
+	filename := filepath.Join(dir, "2023-01-10.csv")
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(f, "%d,-1,0.5\n", day.Unix()+30)
+	f.Close()
+
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"), FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "value", OutputColumnName: "last", Method: csvdata.LAST},
+			{InputColumnName: "nothere", OutputColumnName: "missing", Method: csvdata.MEAN},
+		},
+		StartTime:     day,
+		EndTime:       day.Add(23 * time.Hour),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "1m",
+	}
+	stream, err := csvdata.CsvAggregateTableStream(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+
+	// the column not in the file does not hold the rows until the whole file is read
+	row, ok := <-stream.Rows
+	if !ok || !row.Time.Equal(day) {
+		t.Fatalf("first row got %+v", row)
+	}
+	if dropped := stream.DroppedRows(); len(dropped) != 0 {
+		t.Fatalf("the first row came after the whole file was read, dropped rows %v", dropped)
+	}
+
+	rows := 1
+	for row := range stream.Rows {
+		want := float64(row.Time.Sub(day) / time.Second)
+		if row.Values[0] != want || !math.IsNaN(row.Values[1]) {
+			t.Errorf("row %v got %v, want %v and NaN", row.Time, row.Values, want)
+		}
+		rows++
+	}
+	if rows != 23*60+1 {
+		t.Errorf("got %d rows", rows)
+	}
+	// the stray row is before the rows already aggregated
+	if !reflect.DeepEqual(stream.DroppedRows(), map[string]int{filename: 1}) {
+		t.Errorf("dropped rows got %v", stream.DroppedRows())
+	}
+}
+
+// dashboardConfig is shaped like scripts/dashboard, many requests reading the same columns with different windows
+func dashboardConfig() csvdata.CsvAggregateTableConfigs {
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		TimeOffset:    "7h",
+		StartTime:     time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 12, 0, 0, 0, 0, time.UTC),
+		TimePrecision: "second",
+		AggWindow:     "24h",
+	}
+	columns := []string{"temperature_avg_60", "humidity_avg_60", "pressure_avg_60", "wind_speed_avg_60", "wind_direction_pick_10", "dewpoint_avg_60", "solar_radiation_avg_60"}
+	for _, col := range columns {
+		for _, pick := range []string{"7h", "7h30m", "13h30m", "14h", "17h30m", "18h"} {
+			cfg.Requests = append(cfg.Requests, csvdata.RequestColumnTable{InputColumnName: col, OutputColumnName: col + "_" + pick, Method: csvdata.PICK, PickRelative: pick})
+		}
+		cfg.Requests = append(cfg.Requests,
+			csvdata.RequestColumnTable{InputColumnName: col, OutputColumnName: col + "_last", Method: csvdata.LAST},
+			csvdata.RequestColumnTable{InputColumnName: col, OutputColumnName: col + "_max", Method: csvdata.MAX, WindowString: "-5h59m59s_18h"},
+			csvdata.RequestColumnTable{InputColumnName: col, OutputColumnName: col + "_min", Method: csvdata.MIN, WindowString: "-9h59m59s_14h"},
+		)
+	}
+	cfg.Requests = append(cfg.Requests,
+		csvdata.RequestColumnTable{InputColumnName: "precipitation_accum_3600", OutputColumnName: "rain_07", Method: csvdata.SUM, WindowString: "-16h59m59s_7h"},
+		csvdata.RequestColumnTable{InputColumnName: "precipitation_accum_3600", OutputColumnName: "rain_real", Method: csvdata.SUM, WindowString: "7h_23h59m59s"},
+	)
+	return cfg
+}
+
+func BenchmarkCsvAggregateTable_Dashboard(b *testing.B) {
+	cfg := dashboardConfig()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := csvdata.CsvAggregateTable(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCsvAggregateTable_NaNValue(t *testing.T) {
+	// a NaN cell is a value, it is not dropped like a missing one
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	dir := fixtureDir(t)
+	data := "ts,value\n"
+	for i, v := range []string{"1", "NaN", "3", "4"} {
+		data += fmt.Sprintf("%d,%s\n", day.Unix()+int64(i+1)*600, v)
+	}
+	writeFixture(t, filepath.Join(dir, "2023-01-10.csv"), []byte(data))
+
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"), FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "value", OutputColumnName: "sum", Method: csvdata.SUM},
+			{InputColumnName: "value", OutputColumnName: "count", Method: csvdata.COUNT},
+			{InputColumnName: "value", OutputColumnName: "mean", Method: csvdata.MEAN},
+		},
+		StartTime:     day.Add(time.Hour),
+		EndTime:       day.Add(time.Hour),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "1h",
+	}
+	check := func(t *testing.T, values []float64) {
+		t.Helper()
+		if !math.IsNaN(values[0]) || values[1] != 4 || !math.IsNaN(values[2]) {
+			t.Errorf("got sum, count and mean %v, want NaN, 4 and NaN", values)
+		}
+	}
+
+	t.Run("Table", func(t *testing.T) {
+		result, err := csvdata.CsvAggregateTable(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, []float64{(*result.Columns["sum"])[0], (*result.Columns["count"])[0], (*result.Columns["mean"])[0]})
+	})
+
+	t.Run("Stream", func(t *testing.T) {
+		stream, err := csvdata.CsvAggregateTableStream(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer stream.Close()
+		row, ok := <-stream.Rows
+		if !ok {
+			t.Fatal("no row")
+		}
+		check(t, row.Values)
+	})
+}
+
+func TestCsvAggregateTable_SharedColumn(t *testing.T) {
+	// the requests reading the same column share the parsed value, the results must not depend on it
+	single := dashboardConfig()
+	single.Requests = []csvdata.RequestColumnTable{
+		{InputColumnName: "temperature_avg_60", OutputColumnName: "max", Method: csvdata.MAX, WindowString: "-5h59m59s_18h"},
+	}
+	shared := single
+	shared.Requests = append([]csvdata.RequestColumnTable{
+		{InputColumnName: "temperature_avg_60", OutputColumnName: "min", Method: csvdata.MIN, WindowString: "-9h59m59s_14h"},
+		{InputColumnName: "humidity_avg_60", OutputColumnName: "rh", Method: csvdata.LAST},
+	}, single.Requests...)
+
+	want, err := csvdata.CsvAggregateTable(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := csvdata.CsvAggregateTable(shared)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range *want.Columns["max"] {
+		if (*got.Columns["max"])[i] != v {
+			t.Errorf("row %d got %v, want %v", i, (*got.Columns["max"])[i], v)
+		}
+	}
+}
+
+func TestCsvAggregatePoint_MaxConcurrency(t *testing.T) {
+	cfg := csvdata.CsvAggregatePointConfigs{
+		FileConfig: csvdata.FileConfig{
+			FileNamingFormat: "./example/2006-01-02.csv",
+			FileFrequency:    "24h",
+		},
+		Requests: []csvdata.RequestColumn{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "last", Method: csvdata.LAST},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "sum", Method: csvdata.SUM},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "mean", Method: csvdata.MEAN},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "pick", Method: csvdata.PICK, PickTime: time.Date(2023, 1, 11, 5, 0, 30, 0, time.UTC)},
+		},
+		StartTime:     time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 11, 23, 59, 0, 0, time.UTC),
+		TimePrecision: "second",
+	}
+	want, err := csvdata.CsvAggregatePointDetail(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.MaxConcurrency = 4
+	got, err := csvdata.CsvAggregatePointDetail(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, v := range want {
+		if got[key] != v {
+			t.Errorf("%s got %+v, want %+v", key, got[key], v)
+		}
+	}
+
+	cfg.MaxConcurrency = -1
+	if _, err := csvdata.CsvAggregatePoint(cfg); err == nil {
+		t.Error("expected an error for a negative MaxConcurrency")
+	}
+}
+
+func TestCsvAggregatePoint_FileConfigs(t *testing.T) {
+	// hourly files of a second source, sharing the temperature_avg_60 column with the daily files
+	dir := fixtureDir(t)
+	for _, hour := range []time.Time{time.Date(2023, 1, 10, 13, 0, 0, 0, time.UTC), time.Date(2023, 1, 10, 14, 0, 0, 0, time.UTC)} {
+		var sb strings.Builder
+		sb.WriteString("ts,value,temperature_avg_60\n")
+		for i := 0; i < 3600; i += 10 {
+			ts := hour.Unix() + int64(i)
+			fmt.Fprintf(&sb, "%d,%d,100\n", ts, ts-time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC).Unix())
+		}
+		writeFixture(t, filepath.Join(dir, hour.Format("2006-01-02_15.csv")), []byte(sb.String()))
+	}
+
+	daily := csvdata.FileConfig{Name: "daily", FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"}
+	hourly := csvdata.FileConfig{Name: "hourly", FileNamingFormat: filepath.Join(dir, "2006-01-02_15.csv"), FileFrequency: "1h"}
+	cfg := csvdata.CsvAggregatePointConfigs{
+		Requests: []csvdata.RequestColumn{
+			{InputColumnName: "value", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "value", OutputColumnName: "count", Method: csvdata.COUNT},
+			{InputColumnName: "temperature_avg_60", Source: "hourly", OutputColumnName: "temp_count", Method: csvdata.COUNT},
+			{InputColumnName: "hourly.temperature_avg_60", OutputColumnName: "temp_max", Method: csvdata.MAX},
+			{InputColumnName: "daily.temperature_avg_60", OutputColumnName: "daily_count", Method: csvdata.COUNT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 13, 30, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 14, 30, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+	}
+
+	// the embedded FileConfig is ignored when FileConfigs is set
+	cfg.FileConfig = csvdata.FileConfig{FileNamingFormat: "./missing/2006-01-02.csv", FileFrequency: "24h"}
+	cfg.FileConfigs = []csvdata.FileConfig{daily, hourly}
+	got, err := csvdata.CsvAggregatePoint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["first"] != 48600 || got["count"] != 361 {
+		t.Errorf("hourly columns got %v", got)
+	}
+	if got["temp_count"] != 361 || got["temp_max"] != 100 || got["daily_count"] != 61 {
+		t.Errorf("qualified columns got %v", got)
+	}
+
+	// an unqualified column found in both sources is ambiguous
+	ambiguous := cfg
+	ambiguous.Requests = []csvdata.RequestColumn{{InputColumnName: "temperature_avg_60", OutputColumnName: "temp", Method: csvdata.MAX}}
+	if _, err := csvdata.CsvAggregatePoint(ambiguous); err == nil || !strings.Contains(err.Error(), "temperature_avg_60") {
+		t.Errorf("expected an ambiguous column error, got %v", err)
+	}
+	ambiguous.Requests[0].Source = "nowhere"
+	if _, err := csvdata.CsvAggregatePoint(ambiguous); err == nil {
+		t.Error("expected an error for an unknown source")
+	}
+
+	cfg.FileConfigs[1].FileFrequency = "2h"
+	if _, err := csvdata.CsvAggregatePoint(cfg); err == nil {
+		t.Error("expected an error for an invalid FileFrequency")
+	}
+}
+
+func TestCsvAggregateTable_Source(t *testing.T) {
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "count", Method: csvdata.COUNT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 6, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "1h",
+	}
+	want, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the same files as two sources
+	cfg.FileConfigs = []csvdata.FileConfig{
+		{Name: "a", FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		{Name: "b", FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+	}
+	// the sources are found in the headers of the files
+	if err := cfg.Check(); err == nil {
+		t.Error("expected an ambiguous column error from Check")
+	}
+	if _, err := csvdata.CsvAggregateTable(cfg); err == nil {
+		t.Error("expected an ambiguous column error")
+	}
+	if _, err := csvdata.CsvAggregateTableStream(cfg); err == nil {
+		t.Error("expected an ambiguous column error from the stream")
+	}
+
+	// Check only reads the first file of every source, the column is in a later file of b
+	dir := fixtureDir(t)
+	writeFixture(t, filepath.Join(dir, "2023-01-10.csv"), []byte(fmt.Sprintf("ts,other\n%d,1\n", time.Date(2023, 1, 10, 6, 0, 0, 0, time.UTC).Unix())))
+	writeFixture(t, filepath.Join(dir, "2023-01-11.csv"), readExample(t, "2023-01-11.csv"))
+	later := cfg
+	later.Requests = append([]csvdata.RequestColumnTable{}, cfg.Requests...)
+	later.FileConfigs = []csvdata.FileConfig{
+		{Name: "a", FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		{Name: "b", FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"), FileFrequency: "24h"},
+	}
+	later.EndTime = time.Date(2023, 1, 11, 12, 0, 0, 0, time.UTC)
+	if err := later.Check(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := csvdata.CsvAggregateTable(later); err == nil {
+		t.Error("expected an ambiguous column error while reading")
+	}
+	stream, err := csvdata.CsvAggregateTableStream(later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range stream.Rows {
+	}
+	if stream.Err() == nil {
+		t.Error("expected an ambiguous column error from the stream while reading")
+	}
+
+	cfg.Requests[0].InputColumnName = "a.temperature_avg_60"
+	cfg.Requests[1].Source = "b"
+	got, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"first", "count"} {
+		for i, v := range *want.Columns[col] {
+			if (*got.Columns[col])[i] != v {
+				t.Errorf("%s row %d got %v, want %v", col, i, (*got.Columns[col])[i], v)
+			}
+		}
+	}
+
+	cfg.FileConfigs[1].Name = "a"
+	if _, err := csvdata.CsvAggregateTable(cfg); err == nil {
+		t.Error("expected an error for a duplicated source name")
+	}
+}
@@ -0,0 +1,240 @@
+package csvdata
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// IndexSuffix is appended to the csv file name to get the name of its index
+const IndexSuffix = ".idx"
+
+// rows of one index block
+const indexBlockRows = 1024
+
+var indexMagic = [8]byte{'C', 'S', 'V', 'I', 'D', 'X', '0', '1'}
+
+// indexBlock is a run of rows starting at offset, with the lowest and highest epoch of the rows
+type indexBlock struct {
+	Offset   int64
+	MinEpoch int64
+	MaxEpoch int64
+}
+
+// csvIndex is the content of an index file. It is up to date when the size and
+// the modification time of the csv file are the same as when it was built.
+type csvIndex struct {
+	size    int64
+	modTime int64
+	end     int64 // offset after the last row
+	columns []string
+	blocks  []indexBlock
+}
+
+// BuildIndex reads a csv file and writes its index next to it, in filename + IndexSuffix.
+// The index stores the column names and the byte offset and epoch range of every block of rows,
+// so the readers can skip the rows out of the read range even when the file is not sorted.
+func BuildIndex(filename string) error {
+	_, err := buildIndex(filename)
+	return err
+}
+
+func buildIndex(filename string) (*csvIndex, error) {
+	csvfile, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer csvfile.Close()
+	info, err := csvfile.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &csvIndex{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	header, offset, err := readHeaderLine(bufio.NewReader(csvfile))
+	if err != nil {
+		return nil, err
+	}
+	idx.columns = header
+	if _, err := csvfile.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// the rows are read like the readers do, so the index stops at the first invalid row like they do
+	reader := newRowReader(csvfile, len(header))
+	reader.project([]int{0})
+	rows := 0
+	idx.end = offset
+	for {
+		rowStart := offset + reader.offset
+		line, err := reader.Read()
+		if err != nil {
+			break
+		}
+		if rows%indexBlockRows == 0 {
+			idx.blocks = append(idx.blocks, indexBlock{Offset: rowStart, MinEpoch: math.MaxInt64, MaxEpoch: math.MinInt64})
+		}
+		rows++
+		idx.end = offset + reader.offset
+
+		epoch, ok := parseEpochBytes(line[0])
+		if !ok {
+			continue
+		}
+		block := &idx.blocks[len(idx.blocks)-1]
+		if epoch < block.MinEpoch {
+			block.MinEpoch = epoch
+		}
+		if epoch > block.MaxEpoch {
+			block.MaxEpoch = epoch
+		}
+	}
+
+	// write to a temporary file first so a reader never sees a partial index,
+	// every build has its own temporary file so concurrent builds do not mix
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+IndexSuffix+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	tmpname := tmp.Name()
+	tmp.Close()
+	if err := idx.write(tmpname); err != nil {
+		os.Remove(tmpname)
+		return nil, err
+	}
+	if err := os.Rename(tmpname, filename+IndexSuffix); err != nil {
+		os.Remove(tmpname)
+		return nil, err
+	}
+	return idx, nil
+}
+
+func (idx *csvIndex) write(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	le := binary.LittleEndian
+	binary.Write(w, le, indexMagic)
+	binary.Write(w, le, [3]int64{idx.size, idx.modTime, idx.end})
+	binary.Write(w, le, uint32(len(idx.columns)))
+	for _, col := range idx.columns {
+		binary.Write(w, le, uint32(len(col)))
+		w.WriteString(col)
+	}
+	binary.Write(w, le, uint32(len(idx.blocks)))
+	binary.Write(w, le, idx.blocks)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// readIndex reads the index of a csv file, it is an error when the index is missing, out of date or corrupt
+func readIndex(filename string, info os.FileInfo) (*csvIndex, error) {
+	f, err := os.Open(filename + IndexSuffix)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	idxinfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	// the counts read are checked against the size of the index, so a corrupt index can not make a huge allocation
+	idxsize := idxinfo.Size()
+
+	r := bufio.NewReader(f)
+	le := binary.LittleEndian
+	var magic [8]byte
+	var meta [3]int64
+	if err := binary.Read(r, le, &magic); err != nil {
+		return nil, err
+	}
+	if magic != indexMagic {
+		return nil, fmt.Errorf("%s is not a csv index", filename+IndexSuffix)
+	}
+	if err := binary.Read(r, le, &meta); err != nil {
+		return nil, err
+	}
+	idx := &csvIndex{size: meta[0], modTime: meta[1], end: meta[2]}
+	if idx.size != info.Size() || idx.modTime != info.ModTime().UnixNano() {
+		return nil, fmt.Errorf("index of %s is out of date", filename)
+	}
+	if idx.end < 0 || idx.end > idx.size {
+		return nil, fmt.Errorf("index of %s is corrupt", filename)
+	}
+
+	var n uint32
+	if err := binary.Read(r, le, &n); err != nil {
+		return nil, err
+	}
+	if int64(n)*4 > idxsize {
+		return nil, fmt.Errorf("index of %s is corrupt", filename)
+	}
+	idx.columns = make([]string, n)
+	for i := range idx.columns {
+		var l uint32
+		if err := binary.Read(r, le, &l); err != nil {
+			return nil, err
+		}
+		if int64(l) > idxsize {
+			return nil, fmt.Errorf("index of %s is corrupt", filename)
+		}
+		col := make([]byte, l)
+		if _, err := io.ReadFull(r, col); err != nil {
+			return nil, err
+		}
+		idx.columns[i] = string(col)
+	}
+	if err := binary.Read(r, le, &n); err != nil {
+		return nil, err
+	}
+	if int64(n)*int64(binary.Size(indexBlock{})) > idxsize {
+		return nil, fmt.Errorf("index of %s is corrupt", filename)
+	}
+	idx.blocks = make([]indexBlock, n)
+	if err := binary.Read(r, le, idx.blocks); err != nil {
+		return nil, err
+	}
+	for i, block := range idx.blocks {
+		if block.Offset < 0 || block.Offset > idx.end || (i > 0 && block.Offset < idx.blocks[i-1].Offset) {
+			return nil, fmt.Errorf("index of %s is corrupt", filename)
+		}
+	}
+	return idx, nil
+}
+
+// rangeOf returns the byte range holding every row with an epoch between startEp and endEp
+func (idx *csvIndex) rangeOf(startEp int64, endEp int64) (int64, int64) {
+	start := idx.end
+	for _, block := range idx.blocks {
+		if block.MaxEpoch >= startEp && block.MinEpoch <= block.MaxEpoch {
+			start = block.Offset
+			break
+		}
+	}
+	end := start
+	for i := len(idx.blocks) - 1; i >= 0; i-- {
+		block := idx.blocks[i]
+		if block.MinEpoch <= endEp && block.MinEpoch <= block.MaxEpoch {
+			if i+1 < len(idx.blocks) {
+				end = idx.blocks[i+1].Offset
+			} else {
+				end = idx.end
+			}
+			break
+		}
+	}
+	if end < start {
+		end = start
+	}
+	return start, end
+}
@@ -0,0 +1,251 @@
+package csvdata_test
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func TestBuildIndex(t *testing.T) {
+	dir := writeSecondFile(t, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+	filename := filepath.Join(dir, "2023-01-10.csv")
+
+	// reverse the rows of the afternoon, so the file is not sorted
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(f, "ts,value,other")
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC).Unix()
+	for i := 0; i < 43200; i++ {
+		fmt.Fprintf(f, "%d,%d,%d.5\n", day+int64(i), i, i%60)
+	}
+	for i := 86399; i >= 43200; i-- {
+		fmt.Fprintf(f, "%d,%d,%d.5\n", day+int64(i), i, i%60)
+	}
+	f.Close()
+
+	want, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csvdata.BuildIndex(filename); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filename + csvdata.IndexSuffix); err != nil {
+		t.Fatal(err)
+	}
+	got, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, v := range want {
+		if got[key] != v && !(math.IsNaN(got[key]) && math.IsNaN(v)) {
+			t.Errorf("%s got %v, want %v", key, got[key], v)
+		}
+	}
+
+	// the morning is sorted
+	cfg := secondPointConfig(dir, false)
+	cfg.StartTime = time.Date(2023, 1, 10, 1, 0, 0, 0, time.UTC)
+	cfg.EndTime = time.Date(2023, 1, 10, 1, 0, 59, 0, time.UTC)
+	res, err := csvdata.CsvAggregatePoint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["first"] != 3600 || res["count"] != 60 {
+		t.Errorf("morning got %v", res)
+	}
+
+	// append a row, the index is out of date and not used
+	f, err = os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(f, "%d,%d,%d.5\n", day+3600, -1, 0)
+	f.Close()
+	res, err = csvdata.CsvAggregatePoint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["count"] != 61 {
+		t.Errorf("appended got %v", res)
+	}
+
+	// rebuild on demand
+	before, err := os.Stat(filename + csvdata.IndexSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.BuildIndex = true
+	res, err = csvdata.CsvAggregatePoint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["count"] != 61 {
+		t.Errorf("rebuilt got %v", res)
+	}
+	after, err := os.Stat(filename + csvdata.IndexSuffix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() == before.Size() && after.ModTime().Equal(before.ModTime()) {
+		t.Error("index is not rebuilt")
+	}
+}
+
+func TestCsvAggregateTable_Index(t *testing.T) {
+	dir := writeSecondFile(t, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"), FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "value", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "other", OutputColumnName: "sum", Method: csvdata.SUM},
+		},
+		StartTime:     time.Date(2023, 1, 10, 18, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 19, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "10m",
+	}
+	want, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.FileConfigs[0].BuildIndex = true
+	got, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "2023-01-10.csv"+csvdata.IndexSuffix)); err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"first", "sum"} {
+		for i, v := range *want.Columns[col] {
+			if (*got.Columns[col])[i] != v {
+				t.Errorf("%s row %d got %v, want %v", col, i, (*got.Columns[col])[i], v)
+			}
+		}
+	}
+}
+
+func BenchmarkCsvAggregatePoint_Index(b *testing.B) {
+	dir := writeSecondFile(b, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+	if err := csvdata.BuildIndex(filepath.Join(dir, "2023-01-10.csv")); err != nil {
+		b.Fatal(err)
+	}
+	cfg := secondPointConfig(dir, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := csvdata.CsvAggregatePoint(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBuildIndex_Concurrent(t *testing.T) {
+	dir := writeSecondFile(t, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+	filename := filepath.Join(dir, "2023-01-10.csv")
+
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func() { errs <- csvdata.BuildIndex(filename) }()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	tmps, err := filepath.Glob(filename + csvdata.IndexSuffix + "*.tmp")
+	if err != nil || len(tmps) != 0 {
+		t.Errorf("temporary files left %v %v", tmps, err)
+	}
+	res, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res["first"] != 48600 || res["count"] != 61 {
+		t.Errorf("got %v", res)
+	}
+}
+
+func TestReadIndex_Corrupt(t *testing.T) {
+	dir := writeSecondFile(t, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+	filename := filepath.Join(dir, "2023-01-10.csv")
+	want, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csvdata.BuildIndex(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// a huge block count, after the magic, the meta, and the columns ts, value and other
+	f, err := os.OpenFile(filename+csvdata.IndexSuffix, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, 8+24+4+(4+2)+(4+5)+(4+5)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	got, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, v := range want {
+		if got[key] != v && !(math.IsNaN(got[key]) && math.IsNaN(v)) {
+			t.Errorf("%s got %v, want %v", key, got[key], v)
+		}
+	}
+
+	// a truncated index
+	if err := os.Truncate(filename+csvdata.IndexSuffix, 20); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBuildIndex_BareQuote(t *testing.T) {
+	dir := writeSecondFile(t, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+	filename := filepath.Join(dir, "2023-01-10.csv")
+
+	// bare quotes in the morning, the strict csv reader stops there but the readers do not
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := fmt.Sprintf("%d,3600,0.5\n", time.Date(2023, 1, 10, 1, 0, 0, 0, time.UTC).Unix())
+	data = []byte(strings.Replace(string(data), bad, strings.Replace(bad, "0.5", `0"5"`, 1), 1))
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := csvdata.BuildIndex(filename); err != nil {
+		t.Fatal(err)
+	}
+	got, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, v := range want {
+		if got[key] != v && !(math.IsNaN(got[key]) && math.IsNaN(v)) {
+			t.Errorf("%s got %v, want %v", key, got[key], v)
+		}
+	}
+}
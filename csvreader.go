@@ -0,0 +1,339 @@
+package csvdata
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// below this many bytes the binary search stops and the rows are read one by one
+const seekMinBlock = 64 * 1024
+
+// openCSV opens a csv file and reads its header, the reader skips the rows that are known to be out of startEp to endEp.
+// An up to date index is used when there is one, it is built first when filec.BuildIndex is set.
+// Otherwise when filec.Sorted is set, the rows are known to be sorted by time and the reader starts at a row
+// before the first row at or after startEp, found with a binary search. The search assumes the fields have no quoted newline.
+func openCSV(filename string, filec FileConfig, startEp int64, endEp int64) (*os.File, *rowReader, []string, error) {
+	csvfile, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	info, err := csvfile.Stat()
+	if err != nil {
+		csvfile.Close()
+		return nil, nil, nil, err
+	}
+
+	// use the index
+	idx, err := readIndex(filename, info)
+	if err != nil && filec.BuildIndex {
+		idx, err = buildIndex(filename)
+	}
+	if err == nil {
+		start, end := idx.rangeOf(startEp, endEp)
+		if _, err := csvfile.Seek(start, io.SeekStart); err != nil {
+			csvfile.Close()
+			return nil, nil, nil, err
+		}
+		return csvfile, newRowReader(io.LimitReader(csvfile, end-start), len(idx.columns)), idx.columns, nil
+	}
+
+	// read the header line, its length is where the rows start
+	header, offset, err := readHeaderLine(bufio.NewReader(csvfile))
+	if err != nil {
+		csvfile.Close()
+		return nil, nil, nil, err
+	}
+
+	if filec.Sorted {
+		offset = seekSorted(csvfile, offset, info.Size(), startEp)
+	}
+	if _, err := csvfile.Seek(offset, io.SeekStart); err != nil {
+		csvfile.Close()
+		return nil, nil, nil, err
+	}
+
+	return csvfile, newRowReader(csvfile, len(header)), header, nil
+}
+
+// readHeaderLine reads the header of a csv file, it returns the column names and the length of the header line
+func readHeaderLine(br *bufio.Reader) ([]string, int64, error) {
+	headerLine, err := br.ReadString('\n')
+	if err != nil && (err != io.EOF || headerLine == "") {
+		return nil, 0, err
+	}
+	header, err := csv.NewReader(strings.NewReader(headerLine)).Read()
+	if err != nil {
+		return nil, 0, err
+	}
+	return header, int64(len(headerLine)), nil
+}
+
+// seekSorted returns the offset of a row start between lo and hi, no row before it has an epoch at or after startEp.
+// lo must be a row start. A row that can not be parsed is treated as a row at or after startEp,
+// so the search can only end earlier than needed.
+func seekSorted(f io.ReaderAt, lo int64, hi int64, startEp int64) int64 {
+	size := hi
+	for hi-lo > seekMinBlock {
+		mid := lo + (hi-lo)/2
+		br := bufio.NewReaderSize(io.NewSectionReader(f, mid, size-mid), 8*1024)
+
+		// skip the partial row at mid
+		skipped, err := br.ReadSlice('\n')
+		if err != nil {
+			hi = mid
+			continue
+		}
+		rowStart := mid + int64(len(skipped))
+		if rowStart >= hi {
+			hi = mid
+			continue
+		}
+
+		row, err := br.ReadSlice('\n')
+		if err != nil && err != io.EOF {
+			hi = mid
+			continue
+		}
+		epoch, ok := parseEpochField(row)
+		if !ok || epoch >= startEp {
+			hi = mid
+		} else {
+			lo = rowStart
+		}
+	}
+	return lo
+}
+
+// parseEpochField parses the first field of a csv row as an epoch
+func parseEpochField(row []byte) (int64, bool) {
+	if i := bytes.IndexAny(row, ",\r\n"); i >= 0 {
+		row = row[:i]
+	}
+	row = bytes.Trim(bytes.TrimSpace(row), `"`)
+	epoch, err := strconv.ParseInt(string(row), 10, 64)
+	return epoch, err == nil
+}
+
+var errFieldCount = errors.New("wrong number of fields")
+
+// rowReader reads the rows of a csv file like csv.Reader, but only the projected fields are extracted
+// and they are slices of a reused buffer, so reading a row does not allocate.
+type rowReader struct {
+	br      *bufio.Reader
+	nfields int
+	slots   []int // slots[i] is the position of the column i in fields, -1 when it is not projected
+	fields  [][]byte
+	line    []byte
+	offset  int64 // bytes read from the start of the reader, the start of the next row
+}
+
+func newRowReader(r io.Reader, nfields int) *rowReader {
+	return &rowReader{br: bufio.NewReaderSize(r, 64*1024), nfields: nfields}
+}
+
+// project sets the columns extracted by Read, the field i of a row is the column cols[i], nil when cols[i] is -1
+func (rr *rowReader) project(cols []int) {
+	rr.slots = make([]int, rr.nfields)
+	for i := range rr.slots {
+		rr.slots[i] = -1
+	}
+	for i, col := range cols {
+		if col >= 0 && col < rr.nfields {
+			rr.slots[col] = i
+		}
+	}
+	rr.fields = make([][]byte, len(cols))
+}
+
+// projectColumns projects the time column, the first column, and the named columns.
+// It returns the field of every name in the rows, -1 when the column is not in the header or the name is empty.
+func (rr *rowReader) projectColumns(header []string, names []string) []int {
+	cols := []int{0}
+	fieldOf := make([]int, len(names))
+	for i, name := range names {
+		fieldOf[i] = -1
+		if name == "" {
+			// not read from this file
+			continue
+		}
+		col := findString(header, name)
+		if col == -1 {
+			continue
+		}
+		for f, c := range cols {
+			if c == col {
+				fieldOf[i] = f
+			}
+		}
+		if fieldOf[i] == -1 {
+			cols = append(cols, col)
+			fieldOf[i] = len(cols) - 1
+		}
+	}
+	rr.project(cols)
+	return fieldOf
+}
+
+// Read reads the next row and returns the projected fields, they are only valid until the next Read.
+// Empty lines are skipped, a row with a different number of fields than the header is an error.
+func (rr *rowReader) Read() ([][]byte, error) {
+	var line []byte
+	for len(line) == 0 {
+		var err error
+		line, err = rr.readRecord()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	field := 0
+	for i := 0; ; i++ {
+		var val []byte
+		if i < len(line) && line[i] == '"' {
+			// quoted field, unquoted in place
+			j, w := i+1, i+1
+			for j < len(line) {
+				if line[j] == '"' {
+					if j+1 < len(line) && line[j+1] == '"' {
+						line[w] = '"'
+						w++
+						j += 2
+						continue
+					}
+					j++
+					break
+				}
+				line[w] = line[j]
+				w++
+				j++
+			}
+			val = line[i+1 : w]
+			for j < len(line) && line[j] != ',' {
+				j++
+			}
+			i = j
+		} else {
+			j := bytes.IndexByte(line[i:], ',')
+			if j < 0 {
+				j = len(line)
+			} else {
+				j += i
+			}
+			val = line[i:j]
+			i = j
+		}
+		if field < len(rr.slots) && rr.slots[field] >= 0 {
+			rr.fields[rr.slots[field]] = val
+		}
+		field++
+		if i >= len(line) {
+			break
+		}
+	}
+	if field != rr.nfields {
+		return nil, errFieldCount
+	}
+	return rr.fields, nil
+}
+
+// readRecord reads the lines of one record without the line ending, a quoted field may span several lines
+func (rr *rowReader) readRecord() ([]byte, error) {
+	rr.line = rr.line[:0]
+	quotes := 0
+	for {
+		chunk, err := rr.br.ReadSlice('\n')
+		rr.offset += int64(len(chunk))
+		quotes += bytes.Count(chunk, []byte{'"'})
+		if err == nil && quotes%2 == 0 && len(rr.line) == 0 {
+			// the usual case, the whole record is in the buffer
+			return trimLineEnd(chunk), nil
+		}
+		rr.line = append(rr.line, chunk...)
+		switch {
+		case err == bufio.ErrBufferFull:
+			continue
+		case err == io.EOF && len(rr.line) == 0:
+			return nil, io.EOF
+		case err != nil && err != io.EOF:
+			return nil, err
+		case err == nil && quotes%2 == 1:
+			continue
+		}
+		return trimLineEnd(rr.line), nil
+	}
+}
+
+func trimLineEnd(line []byte) []byte {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+		if n := len(line); n > 0 && line[n-1] == '\r' {
+			line = line[:n-1]
+		}
+	}
+	return line
+}
+
+// parseEpochBytes parses a projected field as an epoch, the conversion to string does not allocate
+func parseEpochBytes(b []byte) (int64, bool) {
+	epoch, err := strconv.ParseInt(string(b), 10, 64)
+	return epoch, err == nil
+}
+
+// parseFloatBytes parses a projected field as a float, the conversion to string does not allocate
+func parseFloatBytes(b []byte) (float64, bool) {
+	value, err := strconv.ParseFloat(string(b), 64)
+	return value, err == nil
+}
+
+// fieldDecoder parses the fields read by the requests once per row, so the requests reading the same column share the value
+type fieldDecoder struct {
+	fieldOf []int // field of every request, -1 when the column is not found
+	used    []int // distinct fields of the requests
+	values  []float64
+	valid   []bool
+}
+
+func newFieldDecoder(fieldOf []int) *fieldDecoder {
+	fd := &fieldDecoder{fieldOf: fieldOf}
+	nfields := 0
+	for _, f := range fieldOf {
+		if f < 0 {
+			continue
+		}
+		if f >= nfields {
+			nfields = f + 1
+		}
+		used := false
+		for _, u := range fd.used {
+			used = used || u == f
+		}
+		if !used {
+			fd.used = append(fd.used, f)
+		}
+	}
+	fd.values = make([]float64, nfields)
+	fd.valid = make([]bool, nfields)
+	return fd
+}
+
+// decode parses the used fields of a row
+func (fd *fieldDecoder) decode(fields [][]byte) {
+	for _, f := range fd.used {
+		fd.values[f], fd.valid[f] = parseFloatBytes(fields[f])
+	}
+}
+
+// value returns the value of the request i in the decoded row, false when the column is not found or the value is not valid
+func (fd *fieldDecoder) value(i int) (float64, bool) {
+	f := fd.fieldOf[i]
+	if f < 0 {
+		return 0, false
+	}
+	return fd.values[f], fd.valid[f]
+}
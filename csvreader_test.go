@@ -0,0 +1,175 @@
+package csvdata_test
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+// writeSecondFile writes a day of one second rows in a fixture directory, value is the second of the day
+func writeSecondFile(tb testing.TB, day time.Time) string {
+	dir := fixtureDir(tb)
+
+	f, err := os.Create(filepath.Join(dir, day.Format("2006-01-02.csv")))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	fmt.Fprintln(w, "ts,value,other")
+	for i := 0; i < 86400; i++ {
+		fmt.Fprintf(w, "%d,%d,%d.5\n", day.Unix()+int64(i), i, i%60)
+	}
+	if err := w.Flush(); err != nil {
+		tb.Fatal(err)
+	}
+	return dir
+}
+
+func secondPointConfig(dir string, sorted bool) csvdata.CsvAggregatePointConfigs {
+	return csvdata.CsvAggregatePointConfigs{
+		FileConfig: csvdata.FileConfig{
+			FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"),
+			FileFrequency:    "24h",
+			Sorted:           sorted,
+		},
+		Requests: []csvdata.RequestColumn{
+			{InputColumnName: "value", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "value", OutputColumnName: "last", Method: csvdata.LAST},
+			{InputColumnName: "value", OutputColumnName: "count", Method: csvdata.COUNT},
+			{InputColumnName: "other", OutputColumnName: "mean", Method: csvdata.MEAN},
+			{InputColumnName: "value", OutputColumnName: "pick", Method: csvdata.PICK, PickTime: time.Date(2023, 1, 10, 13, 30, 30, 0, time.UTC)},
+		},
+		StartTime:     time.Date(2023, 1, 10, 13, 30, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 13, 31, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+	}
+}
+
+func TestCsvAggregatePoint_Sorted(t *testing.T) {
+	dir := writeSecondFile(t, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+
+	want, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := csvdata.CsvAggregatePoint(secondPointConfig(dir, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want["first"] != 48600 || want["last"] != 48660 || want["count"] != 61 || want["pick"] != 48630 {
+		t.Fatalf("unexpected result %v", want)
+	}
+	for key, v := range want {
+		if got[key] != v && !(math.IsNaN(got[key]) && math.IsNaN(v)) {
+			t.Errorf("%s got %v, want %v", key, got[key], v)
+		}
+	}
+
+	// the start of the file and a range past the end of the file
+	for _, start := range []time.Time{time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC), time.Date(2023, 1, 10, 23, 59, 30, 0, time.UTC)} {
+		cfg := secondPointConfig(dir, true)
+		cfg.StartTime = start
+		cfg.EndTime = start.Add(2 * time.Minute)
+		res, err := csvdata.CsvAggregatePoint(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res["first"] != float64(start.Sub(time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))/time.Second) {
+			t.Errorf("start %s first got %v", start, res["first"])
+		}
+	}
+}
+
+func TestCsvAggregateTable_Sorted(t *testing.T) {
+	dir := writeSecondFile(t, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"), FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "value", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "value", OutputColumnName: "count", Method: csvdata.COUNT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 18, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 19, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "10m",
+	}
+	want, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.FileConfigs[0].Sorted = true
+	got, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"first", "count"} {
+		for i, v := range *want.Columns[col] {
+			if (*got.Columns[col])[i] != v {
+				t.Errorf("%s row %d got %v, want %v", col, i, (*got.Columns[col])[i], v)
+			}
+		}
+	}
+}
+
+func BenchmarkCsvAggregatePoint_Scan(b *testing.B) {
+	dir := writeSecondFile(b, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+	cfg := secondPointConfig(dir, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := csvdata.CsvAggregatePoint(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCsvAggregatePoint_Sorted(b *testing.B) {
+	dir := writeSecondFile(b, time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC))
+	cfg := secondPointConfig(dir, true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := csvdata.CsvAggregatePoint(cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestReadProjected(t *testing.T) {
+	content := "1,\"a,b\",2.5,x\r\n" +
+		"\n" +
+		"2,\"say \"\"hi\"\"\",3.5,\"multi\nline\"\n" +
+		"3,,4.5,\n" +
+		"4,c,5.5,y"
+	header := []string{"ts", "text", "value", "note"}
+
+	got, err := csvdata.ReadProjected(strings.NewReader(content), header, []string{"value", "text", "missing", "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"1", "2.5", "a,b", "", "2.5"},
+		{"2", "3.5", `say "hi"`, "", "3.5"},
+		{"3", "4.5", "", "", "4.5"},
+		{"4", "5.5", "c", "", "5.5"},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// a row with a different number of fields stops the reading, like csv.Reader
+	got, err = csvdata.ReadProjected(strings.NewReader("1,a,2.5,x\n2,b\n3,c,4.5,z\n"), header, []string{"value"})
+	if err == nil || len(got) != 1 {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
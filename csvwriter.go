@@ -0,0 +1,118 @@
+package csvdata
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+// CSVWriterOptions controls how WriteCSV writes a SAResult, the zero value writes like SaveToCSV
+type CSVWriterOptions struct {
+	TimeColumnName  string         // name of the time column, empty means "timeResultEp"
+	TimeLayout      string         // Golang time layout of the time column, empty means time.DateTime
+	TimeEpoch       string         // SECOND, MILLI, MICRO or NANO, writes the time as epoch instead of TimeLayout
+	TimeLocation    *time.Location // zone of the time column, nil means UTC
+	Delimiter       rune           // field delimiter, zero means ','
+	Precision       int            // decimal places of the values, only used when FixedPrecision is set
+	FixedPrecision  bool           // round the values to Precision decimal places, otherwise the smallest number of digits necessary is used
+	ColumnPrecision map[string]int // decimal places per output column, overrides Precision and FixedPrecision
+	NaN             string         // representation of NaN values, empty means "NaN"
+	EmptyNaN        bool           // write NaN values as empty fields, overrides NaN
+	NoHeader        bool           // do not write the header
+}
+
+// NewCSVWriterOptions returns the options used by SaveToCSV
+func NewCSVWriterOptions() CSVWriterOptions {
+	return CSVWriterOptions{
+		TimeColumnName: "timeResultEp",
+		TimeLayout:     time.DateTime,
+		TimeLocation:   time.UTC,
+		Delimiter:      ',',
+		NaN:            "NaN",
+	}
+}
+
+// WriteCSV writes the SAResult as csv to w, columns are in request order
+func (result SAResult) WriteCSV(w io.Writer, opts CSVWriterOptions) error {
+	if opts.TimeColumnName == "" {
+		opts.TimeColumnName = "timeResultEp"
+	}
+	if opts.TimeLayout == "" {
+		opts.TimeLayout = time.DateTime
+	}
+	if opts.TimeLocation == nil {
+		opts.TimeLocation = time.UTC
+	}
+	if opts.NaN == "" {
+		opts.NaN = "NaN"
+	}
+	if opts.EmptyNaN {
+		opts.NaN = ""
+	}
+	switch opts.TimeEpoch {
+	case "", SECOND, MILLI, MICRO, NANO:
+	default:
+		return fmt.Errorf("time epoch %s is not valid", opts.TimeEpoch)
+	}
+
+	writer := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		writer.Comma = opts.Delimiter
+	}
+
+	colnames := result.ColumnNames()
+	columns := make([][]float64, len(colnames))
+	precisions := make([]int, len(colnames))
+	for i, colname := range colnames {
+		values, err := result.outputColumn(colname)
+		if err != nil {
+			return err
+		}
+		columns[i] = values
+		precisions[i] = -1
+		if opts.FixedPrecision {
+			precisions[i] = opts.Precision
+		}
+		if prec, ok := opts.ColumnPrecision[colname]; ok {
+			precisions[i] = prec
+		}
+	}
+
+	// Writing the header
+	if !opts.NoHeader {
+		headers := append([]string{opts.TimeColumnName}, colnames...)
+		if err := writer.Write(headers); err != nil {
+			return err
+		}
+	}
+
+	// Writing values
+	line := make([]string, len(colnames)+1)
+	for idx, dte := range *result.TimeStamp {
+		if opts.TimeEpoch != "" {
+			line[0] = strconv.FormatInt(TimetoEpoch(dte, opts.TimeEpoch), 10)
+		} else {
+			line[0] = dte.In(opts.TimeLocation).Format(opts.TimeLayout)
+		}
+
+		for i, values := range columns {
+			switch {
+			case idx >= len(values):
+				line[i+1] = ""
+			case math.IsNaN(values[idx]):
+				line[i+1] = opts.NaN
+			default:
+				line[i+1] = strconv.FormatFloat(values[idx], 'f', precisions[i], 64)
+			}
+		}
+		if err := writer.Write(line); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
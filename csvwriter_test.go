@@ -0,0 +1,115 @@
+package csvdata_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func TestWriteCSV(t *testing.T) {
+	rain := []float64{1.25, math.NaN()}
+	temp := []float64{27.123, 26}
+	timestamp := []time.Time{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	requests := []csvdata.RequestColumnTable{
+		{OutputColumnName: "temp"},
+		{OutputColumnName: "rain"},
+	}
+	result := csvdata.SAResult{
+		Columns:   csvdata.Columns{"rain": &rain, "temp": &temp},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+
+	wib := time.FixedZone("WIB", 7*3600)
+	epochopts := csvdata.NewCSVWriterOptions()
+	epochopts.TimeColumnName = "ts"
+	epochopts.TimeEpoch = csvdata.MILLI
+	epochopts.NoHeader = true
+
+	tests := []struct {
+		name string
+		opts csvdata.CSVWriterOptions
+		want string
+	}{
+		{
+			"Default",
+			csvdata.NewCSVWriterOptions(),
+			"timeResultEp,temp,rain\n2023-01-01 00:00:00,27.123,1.25\n2023-01-02 00:00:00,26,NaN\n",
+		},
+		{
+			"Layout",
+			csvdata.CSVWriterOptions{
+				TimeColumnName:  "time",
+				TimeLayout:      time.RFC3339,
+				TimeLocation:    wib,
+				Delimiter:       ';',
+				Precision:       2,
+				FixedPrecision:  true,
+				ColumnPrecision: map[string]int{"rain": 1},
+				EmptyNaN:        true,
+			},
+			"time;temp;rain\n2023-01-01T07:00:00+07:00;27.12;1.2\n2023-01-02T07:00:00+07:00;26.00;\n",
+		},
+		{
+			"Zero",
+			csvdata.CSVWriterOptions{},
+			"timeResultEp,temp,rain\n2023-01-01 00:00:00,27.123,1.25\n2023-01-02 00:00:00,26,NaN\n",
+		},
+		{
+			"Integer",
+			csvdata.CSVWriterOptions{FixedPrecision: true, NoHeader: true},
+			"2023-01-01 00:00:00,27,1\n2023-01-02 00:00:00,26,NaN\n",
+		},
+		{
+			"Epoch",
+			epochopts,
+			"1672531200000,27.123,1.25\n1672617600000,26,NaN\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := result.WriteCSV(&buf, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCSV_Invalid(t *testing.T) {
+	temp := []float64{27.123}
+	timestamp := []time.Time{time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+	requests := []csvdata.RequestColumnTable{{OutputColumnName: "temp"}}
+	result := csvdata.SAResult{
+		Columns:   csvdata.Columns{"temp": &temp},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+
+	t.Run("TimeEpoch", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := result.WriteCSV(&buf, csvdata.CSVWriterOptions{TimeEpoch: "bogus"}); err == nil {
+			t.Errorf("no error, wrote\n%s", buf.String())
+		}
+	})
+
+	t.Run("MissingColumn", func(t *testing.T) {
+		missing := result
+		rain := append(requests, csvdata.RequestColumnTable{OutputColumnName: "rain"})
+		missing.Requests = &rain
+		var buf bytes.Buffer
+		if err := missing.WriteCSV(&buf, csvdata.CSVWriterOptions{}); err == nil {
+			t.Errorf("no error, wrote\n%s", buf.String())
+		}
+	})
+}
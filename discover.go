@@ -0,0 +1,68 @@
+package csvdata
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// discoverFiles lists the files matching FileGlob, and returns the ones holding rows from startUTC to endUTC in time order.
+// The files whose name has no valid time are ignored.
+func (filec *FileConfig) discoverFiles(startUTC time.Time, endUTC time.Time) []dataFile {
+	pattern := filec.FileGlob
+	if filec.Station != "" {
+		pattern = strings.ReplaceAll(pattern, StationPlaceholder, filec.Station)
+	}
+	names, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil
+	}
+
+	found := []dataFile{}
+	for _, name := range names {
+		if start, ok := filec.fileTime(name); ok {
+			found = append(found, dataFile{name: name, start: start})
+		}
+	}
+	sort.SliceStable(found, func(a, b int) bool {
+		if found[a].start.Equal(found[b].start) {
+			return found[a].name < found[b].name
+		}
+		return found[a].start.Before(found[b].start)
+	})
+
+	// a file ends where the next file with a later time starts
+	files := []dataFile{}
+	for i, file := range found {
+		end := GetNearestPastTimeUnit(file.start, filec.FileFrequency).Add(filec.FileFrequencyDur)
+		for _, next := range found[i+1:] {
+			if next.start.After(file.start) {
+				end = next.start
+				break
+			}
+		}
+		if file.start.After(endUTC) || !end.After(startUTC) {
+			continue
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+// fileTime parses the time of a file from its base name
+func (filec *FileConfig) fileTime(name string) (time.Time, bool) {
+	value := filepath.Base(name)
+	if filec.fileTimeRe != nil {
+		match := filec.fileTimeRe.FindStringSubmatch(value)
+		if match == nil {
+			return time.Time{}, false
+		}
+		value = match[1]
+	}
+	t, err := time.Parse(filec.FileTimeLayout, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.UTC(), true
+}
@@ -0,0 +1,72 @@
+package csvdata_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+// writeLoggerFile writes one minute rows from start to end, value is the minute of the day of start
+func writeLoggerFile(t *testing.T, name string, start time.Time, end time.Time, extra string) {
+	var sb strings.Builder
+	sb.WriteString("ts,value\n")
+	day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	for ts := start; ts.Before(end); ts = ts.Add(time.Minute) {
+		fmt.Fprintf(&sb, "%d,%d\n", ts.Unix(), int(ts.Sub(day)/time.Minute))
+	}
+	sb.WriteString(extra)
+	writeFixture(t, name, []byte(sb.String()))
+}
+
+func TestCsvAggregatePoint_FileGlob(t *testing.T) {
+	dir := fixtureDir(t)
+
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	// the morning file has a stray row in the afternoon, it is out of the period of the file
+	writeLoggerFile(t, filepath.Join(dir, "CR1000_Table1_2023_01_10_0010.dat"), day.Add(10*time.Minute), day.Add(12*time.Hour),
+		fmt.Sprintf("%d,-1\n", day.Add(13*time.Hour).Unix()))
+	writeLoggerFile(t, filepath.Join(dir, "CR1000_Table1_2023_01_10_1200.dat"), day.Add(12*time.Hour), day.Add(24*time.Hour+5*time.Minute), "")
+	writeLoggerFile(t, filepath.Join(dir, "CR1000_Table1_2023_01_11_0005.dat"), day.Add(24*time.Hour+5*time.Minute), day.Add(48*time.Hour), "")
+	writeFixture(t, filepath.Join(dir, "CR1000_Table1_notes.dat"), []byte("not a data file\n"))
+
+	cfg := csvdata.CsvAggregatePointConfigs{
+		FileConfig: csvdata.FileConfig{
+			FileGlob:       filepath.Join(dir, "CR1000_Table1_*.dat"),
+			FileTimeRegexp: `_(\d{4}_\d{2}_\d{2}_\d{4})\.dat$`,
+			FileTimeLayout: "2006_01_02_1504",
+			FileFrequency:  "24h",
+		},
+		Requests: []csvdata.RequestColumn{
+			{InputColumnName: "value", OutputColumnName: "min", Method: csvdata.MIN},
+			{InputColumnName: "value", OutputColumnName: "count", Method: csvdata.COUNT},
+		},
+		TimePrecision: csvdata.SECOND,
+	}
+
+	for _, tc := range []struct {
+		start, end time.Time
+		min, count float64
+	}{
+		{day.Add(11 * time.Hour), day.Add(11*time.Hour + 59*time.Minute), 660, 60},
+		{day.Add(12*time.Hour + 30*time.Minute), day.Add(13*time.Hour + 30*time.Minute), 750, 61},
+		{day.Add(23 * time.Hour), day.Add(24*time.Hour + 9*time.Minute), 5, 70},
+	} {
+		cfg.StartTime, cfg.EndTime = tc.start, tc.end
+		res, err := csvdata.CsvAggregatePoint(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res["min"] != tc.min || res["count"] != tc.count {
+			t.Errorf("%s to %s got %v", tc.start, tc.end, res)
+		}
+	}
+
+	cfg.FileTimeRegexp = `\d+`
+	if _, err := csvdata.CsvAggregatePoint(cfg); err == nil {
+		t.Error("expected an error for a FileTimeRegexp without group")
+	}
+}
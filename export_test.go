@@ -0,0 +1,96 @@
+package csvdata
+
+import (
+	"fmt"
+	"io"
+)
+
+// the projecting row reader is exported to the csvdata_test package only
+
+// ReadProjected reads the rows of r with the projecting row reader, the rows have the time column and the named columns
+func ReadProjected(r io.Reader, header []string, names []string) ([][]string, error) {
+	rr := newRowReader(r, len(header))
+	fieldOf := rr.projectColumns(header, names)
+	rows := [][]string{}
+	for {
+		fields, err := rr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		row := []string{string(fields[0])}
+		for _, f := range fieldOf {
+			if f < 0 {
+				row = append(row, "")
+			} else {
+				row = append(row, string(fields[f]))
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// CountProjected reads the rows of r with the projecting row reader and parses the named columns, it returns the number of rows
+func CountProjected(r io.Reader, header []string, names []string) (int, error) {
+	rr := newRowReader(r, len(header))
+	fieldOf := rr.projectColumns(header, names)
+	n := 0
+	for {
+		fields, err := rr.Read()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		parseEpochBytes(fields[0])
+		for _, f := range fieldOf {
+			parseFloatBytes(fields[f])
+		}
+		n++
+	}
+}
+
+// MergeFiles merges the files of several sources like the table reader, files[s] are the files of the source s
+// and every file is a list of epochs. It returns the epochs in the order an aggregator receives them and the
+// number of rows dropped.
+func MergeFiles(files [][][]int64) ([]int64, int) {
+	agg := &SmartAggregator{Batches: make(chan []Input, 1)}
+	cursors := make([]*sourceCursor, len(files))
+	dropped := 0
+	for s := range files {
+		ch := make(chan *fileRows, len(files[s])+1)
+		runs := newSourceRuns(ch, nil)
+		for f, epochs := range files[s] {
+			rows := newFileRows(1)
+			for _, epoch := range epochs {
+				rows.epochs = append(rows.epochs, epoch)
+				rows.values = append(rows.values, float64(s))
+				rows.present = append(rows.present, true)
+			}
+			runs.put(fmt.Sprint(f), rows)
+		}
+		runs.flush()
+		close(ch)
+		for _, n := range runs.dropped {
+			dropped += n
+		}
+		cursors[s] = &sourceCursor{files: ch}
+	}
+
+	go func() {
+		batcher := newInputBatcher([]*SmartAggregator{agg})
+		mergeSources(cursors, batcher, nil, false)
+		batcher.flush()
+		close(agg.Batches)
+	}()
+	epochs := []int64{}
+	for batch := range agg.Batches {
+		for _, in := range batch {
+			epochs = append(epochs, in.Epoch)
+		}
+	}
+	return epochs, dropped
+}
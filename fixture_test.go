@@ -0,0 +1,68 @@
+package csvdata_test
+
+import (
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixtureLetters make the fixture directory names, none of them is part of a time layout element
+const fixtureLetters = "bcdfghkqrvwxz"
+
+// fixtureDir makes an empty directory for the files of one test, it is removed after the test.
+// The file names are formatted as time layouts, so the path has no digit or layout element:
+// it is in the temporary directory when that path is safe, in example otherwise.
+func fixtureDir(tb testing.TB) string {
+	tb.Helper()
+	parent := os.TempDir()
+	if !layoutSafe(parent) {
+		parent = "example"
+	}
+	for {
+		name := make([]byte, 12)
+		if _, err := rand.Read(name); err != nil {
+			tb.Fatal(err)
+		}
+		for i, b := range name {
+			name[i] = fixtureLetters[int(b)%len(fixtureLetters)]
+		}
+		dir := filepath.Join(parent, "csvdata-"+string(name))
+		err := os.Mkdir(dir, 0o755)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			tb.Fatal(err)
+		}
+		tb.Cleanup(func() { os.RemoveAll(dir) })
+		return dir
+	}
+}
+
+// layoutSafe tells if path is left as is when formatted as a time layout
+func layoutSafe(path string) bool {
+	return time.Date(2345, 11, 22, 13, 44, 55, 666, time.UTC).Format(path) == path
+}
+
+// writeFixture writes a fixture file, making its directory
+func writeFixture(tb testing.TB, name string, data []byte) {
+	tb.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		tb.Fatal(err)
+	}
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// readExample reads an example file, such as 2023-01-10.csv
+func readExample(tb testing.TB, name string) []byte {
+	tb.Helper()
+	data, err := os.ReadFile(filepath.Join("example", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
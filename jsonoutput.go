@@ -0,0 +1,285 @@
+package csvdata
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// json layouts of SAResult
+const (
+	COLUMNS = "columns" // {"Columns":{"name":[values]},"Time":[times]}
+	ROWS    = "rows"    // [{"Time":time,"name":value}]
+)
+
+// MarshalJSON implements json.Marshaler, the layout is chosen by JSONLayout.
+// NaN and infinite values are written as null and the columns follow the request order.
+func (result SAResult) MarshalJSON() ([]byte, error) {
+	return result.ToJSON(result.JSONLayout)
+}
+
+// ToJSON converts the SAResult to standard json in the COLUMNS or ROWS layout, empty means COLUMNS
+func (result SAResult) ToJSON(layout string) ([]byte, error) {
+	switch layout {
+	case "", COLUMNS:
+		return result.marshalColumns()
+	case ROWS:
+		return result.marshalRows()
+	default:
+		return nil, fmt.Errorf("json layout %s is not valid", layout)
+	}
+}
+
+func (result SAResult) marshalColumns() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(`{"Columns":{`)
+
+	// Write results
+	for keyi, key := range result.ColumnNames() {
+		values, err := result.outputColumn(key)
+		if err != nil {
+			return nil, err
+		}
+		if keyi != 0 {
+			buf.WriteString(",")
+		}
+		if err := writeJSONValue(&buf, key); err != nil {
+			return nil, err
+		}
+		buf.WriteString(":[")
+		for i, val := range values {
+			if i != 0 {
+				buf.WriteString(",")
+			}
+			writeJSONFloat(&buf, val)
+		}
+		buf.WriteString("]")
+	}
+
+	buf.WriteString(`},"Time":[`)
+
+	// Write time results
+	if result.TimeStamp != nil {
+		for i, dte := range *result.TimeStamp {
+			if i != 0 {
+				buf.WriteString(",")
+			}
+			if err := writeJSONValue(&buf, dte.UTC()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	buf.WriteString("]}")
+
+	return buf.Bytes(), nil
+}
+
+func (result SAResult) marshalRows() ([]byte, error) {
+	var buf bytes.Buffer
+	colnames := result.ColumnNames()
+
+	// quote the keys once
+	keys := make([][]byte, len(colnames))
+	columns := make([][]float64, len(colnames))
+	for i, key := range colnames {
+		if key == "Time" {
+			return nil, fmt.Errorf("output column Time is the time key of the rows layout, rename it")
+		}
+		values, err := result.outputColumn(key)
+		if err != nil {
+			return nil, err
+		}
+		columns[i] = values
+		quoted, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = quoted
+	}
+
+	buf.WriteString("[")
+	if result.TimeStamp != nil {
+		for idx, dte := range *result.TimeStamp {
+			if idx != 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(`{"Time":`)
+			if err := writeJSONValue(&buf, dte.UTC()); err != nil {
+				return nil, err
+			}
+			for i := range colnames {
+				buf.WriteString(",")
+				buf.Write(keys[i])
+				buf.WriteString(":")
+				if idx < len(columns[i]) {
+					writeJSONFloat(&buf, columns[i][idx])
+				} else {
+					buf.WriteString("null")
+				}
+			}
+			buf.WriteString("}")
+		}
+	}
+	buf.WriteString("]")
+
+	return buf.Bytes(), nil
+}
+
+func writeJSONValue(buf *bytes.Buffer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	return nil
+}
+
+func writeJSONFloat(buf *bytes.Buffer, val float64) {
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		buf.WriteString("null")
+		return
+	}
+	buf.WriteString(strconv.FormatFloat(val, 'f', -1, 64))
+}
+
+// UnmarshalJSON implements json.Unmarshaler, both the COLUMNS and ROWS layouts are accepted.
+// null becomes NaN and the column order is kept in Requests.
+func (result *SAResult) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		result.JSONLayout = ROWS
+		return result.unmarshalRows(data)
+	}
+	result.JSONLayout = COLUMNS
+	return result.unmarshalColumns(data)
+}
+
+func (result *SAResult) unmarshalColumns(data []byte) error {
+	var raw struct {
+		Columns json.RawMessage
+		Time    []time.Time
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	columns := make(Columns)
+	requests := []RequestColumnTable{}
+	if len(raw.Columns) > 0 && !bytes.Equal(raw.Columns, []byte("null")) {
+		// read the columns one by one to keep the order
+		dec := json.NewDecoder(bytes.NewReader(raw.Columns))
+		if err := expectDelim(dec, '{'); err != nil {
+			return err
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+
+			var values []*float64
+			if err := dec.Decode(&values); err != nil {
+				return err
+			}
+			res := make([]float64, len(values))
+			for i, v := range values {
+				res[i] = nullToNaN(v)
+			}
+			columns[key] = &res
+			requests = append(requests, RequestColumnTable{OutputColumnName: key})
+		}
+	}
+
+	result.Columns = columns
+	result.Requests = &requests
+	result.Computed = nil
+	result.TimeStamp = &raw.Time
+	return nil
+}
+
+func (result *SAResult) unmarshalRows(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := expectDelim(dec, '['); err != nil {
+		return err
+	}
+
+	columns := make(Columns)
+	requests := []RequestColumnTable{}
+	timestamp := []time.Time{}
+	for row := 0; dec.More(); row++ {
+		if err := expectDelim(dec, '{'); err != nil {
+			return err
+		}
+		timestamp = append(timestamp, time.Time{})
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := tok.(string)
+
+			if key == "Time" {
+				if err := dec.Decode(&timestamp[row]); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var value *float64
+			if err := dec.Decode(&value); err != nil {
+				return err
+			}
+			res, ok := columns[key]
+			if !ok {
+				// new column, the rows before it have no value
+				newres := make([]float64, row, cap(timestamp))
+				for i := range newres {
+					newres[i] = math.NaN()
+				}
+				res = &newres
+				columns[key] = res
+				requests = append(requests, RequestColumnTable{OutputColumnName: key})
+			}
+			*res = append(*res, nullToNaN(value))
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return err
+		}
+
+		// the columns missing from this row have no value
+		for _, res := range columns {
+			if len(*res) <= row {
+				*res = append(*res, math.NaN())
+			}
+		}
+	}
+
+	result.Columns = columns
+	result.Requests = &requests
+	result.Computed = nil
+	result.TimeStamp = &timestamp
+	return nil
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("expected %s in json, got %v", delim, tok)
+	}
+	return nil
+}
+
+func nullToNaN(v *float64) float64 {
+	if v == nil {
+		return math.NaN()
+	}
+	return *v
+}
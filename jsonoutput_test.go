@@ -0,0 +1,111 @@
+package csvdata_test
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func TestSAResultJSON(t *testing.T) {
+	rain := []float64{1.25, math.NaN()}
+	temp := []float64{27, math.Inf(1)}
+	timestamp := []time.Time{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	requests := []csvdata.RequestColumnTable{
+		{OutputColumnName: "temp \"c\""},
+		{OutputColumnName: "rain"},
+	}
+	result := csvdata.SAResult{
+		Columns:   csvdata.Columns{"rain": &rain, "temp \"c\"": &temp},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+
+	tests := []struct {
+		name   string
+		layout string
+		want   string
+	}{
+		{
+			"Columns",
+			csvdata.COLUMNS,
+			`{"Columns":{"temp \"c\"":[27,null],"rain":[1.25,null]},"Time":["2023-01-01T00:00:00Z","2023-01-02T00:00:00Z"]}`,
+		},
+		{
+			"Rows",
+			csvdata.ROWS,
+			`[{"Time":"2023-01-01T00:00:00Z","temp \"c\"":27,"rain":1.25},{"Time":"2023-01-02T00:00:00Z","temp \"c\"":null,"rain":null}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result.JSONLayout = tt.layout
+			out, err := json.Marshal(result)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", out, tt.want)
+			}
+
+			// it must be valid json
+			var generic interface{}
+			if err := json.Unmarshal(out, &generic); err != nil {
+				t.Error(err)
+			}
+
+			// round trip
+			var back csvdata.SAResult
+			if err := json.Unmarshal(out, &back); err != nil {
+				t.Fatal(err)
+			}
+			if back.JSONLayout != tt.layout {
+				t.Errorf("got layout %s, want %s", back.JSONLayout, tt.layout)
+			}
+			if got := strings.Join(back.ColumnNames(), ","); got != "temp \"c\",rain" {
+				t.Errorf("got column order %s", got)
+			}
+			if (*back.Columns["rain"])[0] != 1.25 || !math.IsNaN((*back.Columns["rain"])[1]) {
+				t.Errorf("got rain %v", *back.Columns["rain"])
+			}
+			if !(*back.TimeStamp)[1].Equal(timestamp[1]) {
+				t.Errorf("got time %v", *back.TimeStamp)
+			}
+		})
+	}
+}
+
+func TestSAResultJSON_Invalid(t *testing.T) {
+	values := []float64{1}
+	timestamp := []time.Time{time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	// a requested column missing from the result
+	requests := []csvdata.RequestColumnTable{{OutputColumnName: "temp"}, {OutputColumnName: "rain"}}
+	result := csvdata.SAResult{
+		Columns:   csvdata.Columns{"temp": &values},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+	for _, layout := range []string{csvdata.COLUMNS, csvdata.ROWS} {
+		if _, err := result.ToJSON(layout); err == nil {
+			t.Errorf("%s: no error for the missing column", layout)
+		}
+	}
+
+	// a column named like the time key of the rows layout
+	requests = []csvdata.RequestColumnTable{{OutputColumnName: "Time"}}
+	result.Columns = csvdata.Columns{"Time": &values}
+	if _, err := result.ToJSON(csvdata.ROWS); err == nil {
+		t.Error("no error for the Time column in the rows layout")
+	}
+	if _, err := result.ToJSON(csvdata.COLUMNS); err != nil {
+		t.Errorf("the columns layout has no time key in the columns: %v", err)
+	}
+}
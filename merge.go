@@ -0,0 +1,273 @@
+package csvdata
+
+import (
+	"sort"
+)
+
+// fileRows are the rows of one file read for the requests of a table
+type fileRows struct {
+	nreq    int
+	epochs  []int64
+	values  []float64 // values[r*nreq+i] is the value of the request i in the row r
+	present []bool    // false when the row has no value for the request, like values
+}
+
+func newFileRows(nreq int) *fileRows {
+	return &fileRows{nreq: nreq}
+}
+
+// add adds a decoded row
+func (fr *fileRows) add(epoch int64, decoder *fieldDecoder) {
+	fr.epochs = append(fr.epochs, epoch)
+	for i := 0; i < fr.nreq; i++ {
+		value, ok := decoder.value(i)
+		fr.values = append(fr.values, value)
+		fr.present = append(fr.present, ok)
+	}
+}
+
+func (fr *fileRows) len() int {
+	return len(fr.epochs)
+}
+
+// sort sorts the rows by epoch, the rows with the same epoch stay in file order. It tells if the rows were not sorted.
+func (fr *fileRows) sort() bool {
+	sorted := sort.SliceIsSorted(fr.epochs, func(a, b int) bool { return fr.epochs[a] < fr.epochs[b] })
+	if sorted {
+		return false
+	}
+
+	order := make([]int, fr.len())
+	for r := range order {
+		order[r] = r
+	}
+	sort.SliceStable(order, func(a, b int) bool { return fr.epochs[order[a]] < fr.epochs[order[b]] })
+
+	epochs := make([]int64, len(fr.epochs))
+	values := make([]float64, len(fr.values))
+	present := make([]bool, len(fr.present))
+	for r, from := range order {
+		epochs[r] = fr.epochs[from]
+		copy(values[r*fr.nreq:(r+1)*fr.nreq], fr.values[from*fr.nreq:(from+1)*fr.nreq])
+		copy(present[r*fr.nreq:(r+1)*fr.nreq], fr.present[from*fr.nreq:(from+1)*fr.nreq])
+	}
+	fr.epochs, fr.values, fr.present = epochs, values, present
+	return true
+}
+
+// slice returns the rows from to to-1, sharing the memory of fr
+func (fr *fileRows) slice(from int, to int) *fileRows {
+	return &fileRows{
+		nreq:    fr.nreq,
+		epochs:  fr.epochs[from:to],
+		values:  fr.values[from*fr.nreq : to*fr.nreq],
+		present: fr.present[from*fr.nreq : to*fr.nreq],
+	}
+}
+
+// mergeRows merges two sorted runs of rows, the rows of a with the same epoch as rows of b come first
+func mergeRows(a *fileRows, b *fileRows) *fileRows {
+	n := a.len() + b.len()
+	merged := &fileRows{
+		nreq:    a.nreq,
+		epochs:  make([]int64, 0, n),
+		values:  make([]float64, 0, n*a.nreq),
+		present: make([]bool, 0, n*a.nreq),
+	}
+	take := func(fr *fileRows, r int) {
+		merged.epochs = append(merged.epochs, fr.epochs[r])
+		merged.values = append(merged.values, fr.values[r*fr.nreq:(r+1)*fr.nreq]...)
+		merged.present = append(merged.present, fr.present[r*fr.nreq:(r+1)*fr.nreq]...)
+	}
+	i, j := 0, 0
+	for i < a.len() || j < b.len() {
+		if j >= b.len() || (i < a.len() && a.epochs[i] <= b.epochs[j]) {
+			take(a, i)
+			i++
+		} else {
+			take(b, j)
+			j++
+		}
+	}
+	return merged
+}
+
+// the files are sorted in runs of runRows rows, at most keptRuns runs are kept per source
+const (
+	runRows  = 4096
+	keptRuns = 4
+)
+
+// sourceOrder puts the rows of one source in time order when its files overlap. Every run of rows, sorted,
+// is merged with the rows kept from the runs before. The runs come in file order, so the kept rows before
+// the first row of a new run are ready to be sent, the others are kept for the next run. With a limit,
+// the oldest rows over the limit are sent anyway. A row before the rows already sent cannot be placed.
+type sourceOrder struct {
+	limit int       // the most rows kept, zero means no limit
+	kept  *fileRows // sorted
+	sent  bool
+	last  int64 // the epoch of the last row sent
+}
+
+// add adds a sorted run, it returns the rows ready to be sent and the number of rows of the run dropped
+// because they are before the rows already sent
+func (so *sourceOrder) add(run *fileRows) (*fileRows, int) {
+	dropped := 0
+	if so.sent {
+		dropped = sort.Search(run.len(), func(r int) bool { return run.epochs[r] >= so.last })
+		run = run.slice(dropped, run.len())
+	}
+	if run.len() == 0 {
+		return run, dropped
+	}
+
+	first := run.epochs[0]
+	var ready *fileRows
+	rows := run
+	if so.kept != nil && so.kept.len() > 0 {
+		if so.kept.epochs[so.kept.len()-1] < first {
+			// no overlap, the kept rows are all ready
+			ready = so.kept
+		} else {
+			rows = mergeRows(so.kept, run)
+		}
+	}
+	n := sort.Search(rows.len(), func(r int) bool { return rows.epochs[r] >= first })
+	if so.limit > 0 && rows.len()-n > so.limit {
+		n = rows.len() - so.limit
+	}
+	so.kept = rows.slice(n, rows.len())
+	switch {
+	case ready == nil:
+		ready = rows.slice(0, n)
+	case n > 0:
+		ready = mergeRows(ready, rows.slice(0, n))
+	}
+	if ready.len() > 0 {
+		so.last, so.sent = ready.epochs[ready.len()-1], true
+	}
+	return ready, dropped
+}
+
+// flush returns the rows kept, after the last run
+func (so *sourceOrder) flush() *fileRows {
+	rows := so.kept
+	so.kept = nil
+	if rows != nil && rows.len() > 0 {
+		so.last, so.sent = rows.epochs[rows.len()-1], true
+	}
+	return rows
+}
+
+// sourceRuns sends the runs of rows of the files of one source to files in time order, see sourceOrder.
+// It records the unsorted files and the rows dropped by file.
+type sourceRuns struct {
+	order    sourceOrder
+	files    chan<- *fileRows
+	stop     <-chan struct{}
+	unsorted []string
+	dropped  map[string]int
+}
+
+func newSourceRuns(files chan<- *fileRows, stop <-chan struct{}) *sourceRuns {
+	return &sourceRuns{order: sourceOrder{limit: keptRuns * runRows}, files: files, stop: stop}
+}
+
+// put sorts a run of rows of the file and sends the rows that are ready, false when the reading stops
+func (sr *sourceRuns) put(file string, rows *fileRows) bool {
+	if rows.len() == 0 {
+		return true
+	}
+	if rows.sort() && (len(sr.unsorted) == 0 || sr.unsorted[len(sr.unsorted)-1] != file) {
+		sr.unsorted = append(sr.unsorted, file)
+	}
+	ready, n := sr.order.add(rows)
+	if n > 0 {
+		if sr.dropped == nil {
+			sr.dropped = map[string]int{}
+		}
+		sr.dropped[file] += n
+	}
+	return sr.send(ready)
+}
+
+// flush sends the rows kept, after the last file
+func (sr *sourceRuns) flush() bool {
+	return sr.send(sr.order.flush())
+}
+
+func (sr *sourceRuns) send(rows *fileRows) bool {
+	if rows == nil || rows.len() == 0 {
+		return true
+	}
+	select {
+	case sr.files <- rows:
+		return true
+	case <-sr.stop:
+		return false
+	}
+}
+
+// sourceCursor is the position of the merge in the rows of one source
+type sourceCursor struct {
+	files <-chan *fileRows // the rows of the source in time order
+	rows  *fileRows
+	pos   int
+}
+
+// load makes the cursor point at a row, false when the source has no row left
+func (sc *sourceCursor) load() bool {
+	for sc.rows == nil || sc.pos >= sc.rows.len() {
+		rows, ok := <-sc.files
+		if !ok {
+			sc.rows = nil
+			return false
+		}
+		sc.rows, sc.pos = rows, 0
+	}
+	return true
+}
+
+// mergeSources sends the rows of every source to the aggregators in time order, the rows with
+// the same epoch are sent in source order. Every source must send its rows in time order.
+// With ticks, a row without a value for a request is sent as a tick, so every request knows the time
+// went on even when its column is not in the file. It stops early when stop is closed.
+func mergeSources(cursors []*sourceCursor, batcher *inputBatcher, stop <-chan struct{}, ticks bool) {
+	active := make([]*sourceCursor, 0, len(cursors))
+	for _, sc := range cursors {
+		if sc.load() {
+			active = append(active, sc)
+		}
+	}
+
+	for len(active) > 0 {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		// the sources are few, a linear search is enough
+		next := 0
+		for s := 1; s < len(active); s++ {
+			if active[s].rows.epochs[active[s].pos] < active[next].rows.epochs[active[next].pos] {
+				next = s
+			}
+		}
+
+		sc := active[next]
+		rows, r := sc.rows, sc.pos
+		epoch := rows.epochs[r]
+		for i := 0; i < rows.nreq; i++ {
+			if rows.present[r*rows.nreq+i] {
+				batcher.add(i, Input{Epoch: epoch, Value: rows.values[r*rows.nreq+i]})
+			} else if ticks {
+				batcher.add(i, Input{Epoch: epoch, tick: true})
+			}
+		}
+		sc.pos++
+		if !sc.load() {
+			active = append(active[:next], active[next+1:]...)
+		}
+	}
+}
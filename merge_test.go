@@ -0,0 +1,204 @@
+package csvdata_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func TestMergeFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   [][][]int64
+		want    []int64
+		dropped int
+	}{
+		{
+			"Sources",
+			[][][]int64{
+				{{1, 4, 7}, {}, {10, 13}},
+				{{2, 5}, {8, 11, 14}},
+				{{6, 3, 9, 0}},
+			},
+			[]int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 14},
+			0,
+		},
+		{
+			// the files of a source overlap, their rows are merged
+			"Overlap",
+			[][][]int64{{{1, 5, 9}, {3, 7, 11}, {10, 12}}},
+			[]int64{1, 3, 5, 7, 9, 10, 11, 12},
+			0,
+		},
+		{
+			// the rows before the rows already sent cannot be placed
+			"Dropped",
+			[][][]int64{{{5, 6}, {7, 8}, {1, 9}}},
+			[]int64{5, 6, 7, 8, 9},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped := csvdata.MergeFiles(tt.files)
+			if !reflect.DeepEqual(got, tt.want) || dropped != tt.dropped {
+				t.Errorf("got %v and %d dropped, want %v and %d dropped", got, dropped, tt.want, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestCsvAggregateTable_Unsorted(t *testing.T) {
+	day := time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC)
+	dir := writeSecondFile(t, day)
+	filename := filepath.Join(dir, "2023-01-10.csv")
+
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: filepath.Join(dir, "2006-01-02.csv"), FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "value", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "value", OutputColumnName: "last", Method: csvdata.LAST},
+			{InputColumnName: "value", OutputColumnName: "pick", Method: csvdata.PICK, PickRelative: "5m"},
+			{InputColumnName: "other", OutputColumnName: "mean", Method: csvdata.MEAN},
+		},
+		StartTime:     time.Date(2023, 1, 10, 11, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 14, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "10m",
+	}
+	want, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want.UnsortedFiles) != 0 {
+		t.Errorf("sorted file reported as unsorted %v", want.UnsortedFiles)
+	}
+
+	// reverse the rows of the afternoon
+	f, err := os.Create(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintln(f, "ts,value,other")
+	for i := 0; i < 43200; i++ {
+		fmt.Fprintf(f, "%d,%d,%d.5\n", day.Unix()+int64(i), i, i%60)
+	}
+	for i := 86399; i >= 43200; i-- {
+		fmt.Fprintf(f, "%d,%d,%d.5\n", day.Unix()+int64(i), i, i%60)
+	}
+	f.Close()
+
+	got, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.UnsortedFiles, []string{filename}) {
+		t.Errorf("unsorted files got %v", got.UnsortedFiles)
+	}
+	for _, col := range []string{"first", "last", "pick", "mean"} {
+		for i, v := range *want.Columns[col] {
+			if (*got.Columns[col])[i] != v {
+				t.Errorf("%s row %d got %v, want %v", col, i, (*got.Columns[col])[i], v)
+			}
+		}
+	}
+
+	// the stream reports it too
+	stream, err := csvdata.CsvAggregateTableStream(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := 0
+	for row := range stream.Rows {
+		if row.Values[0] != (*want.Columns["first"])[rows] {
+			t.Errorf("stream row %d got %v", rows, row.Values)
+		}
+		rows++
+	}
+	if rows != len(*want.TimeStamp) || !reflect.DeepEqual(stream.UnsortedFiles(), []string{filename}) {
+		t.Errorf("stream got %d rows, unsorted files %v", rows, stream.UnsortedFiles())
+	}
+}
+
+func TestCsvAggregateTable_OverlappingFiles(t *testing.T) {
+	// the logger restarted at 12:00 and wrote every other row of the 12:00 hour again in a new file,
+	// the file of 18:00 has a stray row of the morning
+	dir := fixtureDir(t)
+	lines := strings.Split(strings.TrimRight(string(readExample(t, "2023-01-10.csv")), "\n"), "\n")
+	noon := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC).Unix()
+	parts := map[string][]string{}
+	for r, line := range lines[1:] {
+		ts, err := strconv.ParseInt(line[:strings.Index(line, ",")], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case ts < noon || (ts < noon+3600 && r%2 == 0):
+			parts["part_2023-01-10_0000.csv"] = append(parts["part_2023-01-10_0000.csv"], line)
+		case ts < noon+6*3600:
+			parts["part_2023-01-10_1200.csv"] = append(parts["part_2023-01-10_1200.csv"], line)
+		default:
+			parts["part_2023-01-10_1800.csv"] = append(parts["part_2023-01-10_1800.csv"], line)
+		}
+	}
+	stray := strings.Split(lines[61], ",")
+	stray[1] = "-100"
+	parts["part_2023-01-10_1800.csv"] = append(parts["part_2023-01-10_1800.csv"], strings.Join(stray, ","))
+	for name, rows := range parts {
+		writeFixture(t, filepath.Join(dir, name), []byte(lines[0]+"\n"+strings.Join(rows, "\n")+"\n"))
+	}
+
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "last", Method: csvdata.LAST},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "count", Method: csvdata.COUNT},
+			{InputColumnName: "dewpoint_avg_60", OutputColumnName: "min", Method: csvdata.MIN},
+		},
+		StartTime:     time.Date(2023, 1, 10, 0, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 10, 23, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "10m",
+	}
+	want, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.FileConfigs = []csvdata.FileConfig{{
+		FileGlob:       filepath.Join(dir, "part_*.csv"),
+		FileTimeRegexp: `_(\d{4}-\d{2}-\d{2}_\d{4})\.csv$`,
+		FileTimeLayout: "2006-01-02_1504",
+		FileFrequency:  "24h",
+	}}
+	got, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"first", "last", "count", "min"} {
+		for i, v := range *want.Columns[col] {
+			if (*got.Columns[col])[i] != v {
+				t.Errorf("%s row %d got %v, want %v", col, i, (*got.Columns[col])[i], v)
+			}
+		}
+	}
+	strayFile := filepath.Join(dir, "part_2023-01-10_1800.csv")
+	if len(got.UnsortedFiles) != 1 || got.UnsortedFiles[0] != strayFile {
+		t.Errorf("unsorted files got %v", got.UnsortedFiles)
+	}
+	if !reflect.DeepEqual(got.DroppedRows, map[string]int{strayFile: 1}) {
+		t.Errorf("dropped rows got %v", got.DroppedRows)
+	}
+}
@@ -0,0 +1,175 @@
+package csvdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/apache/arrow/go/v11/parquet"
+	"github.com/apache/arrow/go/v11/parquet/pqarrow"
+)
+
+// ParquetOptions controls how WriteParquet writes a SAResult
+type ParquetOptions struct {
+	TimeColumnName string // name of the timestamp column, empty means "time"
+	TimeUnit       string // MILLI, MICRO or NANO unit of the timestamp column, empty means MILLI
+	RowGroupSize   int    // maximum rows of one row group, zero means one row group
+}
+
+// requestMetadata is the metadata of one output column
+type requestMetadata struct {
+	Name     string   `json:"name"`
+	Input    []string `json:"input,omitempty"`
+	Method   string   `json:"method"`
+	Window   string   `json:"window,omitempty"`
+	WindowEp []int64  `json:"window_ep,omitempty"`
+	Pick     string   `json:"pick,omitempty"`
+}
+
+// requestsMetadata returns the requests of the SAResult as json, in column order
+func (result SAResult) requestsMetadata() (string, error) {
+	meta := []requestMetadata{}
+	if result.Requests != nil {
+		for _, req := range *result.Requests {
+			m := requestMetadata{Name: req.OutputColumnName, Method: req.Method}
+			if req.InputColumnName != "" {
+				m.Input = []string{req.InputColumnName}
+			}
+			if req.Method == PICK {
+				m.Pick = req.PickRelative
+			} else {
+				m.Window = req.WindowString
+				if req.WindowEp != [2]int64{} {
+					m.WindowEp = req.WindowEp[:]
+				}
+			}
+			meta = append(meta, m)
+		}
+	}
+	if result.Computed != nil {
+		for _, req := range *result.Computed {
+			m := requestMetadata{Name: req.OutputColumnName, Input: req.InputColumnNames, Method: req.Operator}
+			if req.Func != nil {
+				m.Method = "func"
+			}
+			meta = append(meta, m)
+		}
+	}
+	b, err := json.Marshal(meta)
+	return string(b), err
+}
+
+// arrow units of the time units
+var arrowTimeUnits = map[string]arrow.TimeUnit{
+	SECOND: arrow.Second,
+	MILLI:  arrow.Millisecond,
+	MICRO:  arrow.Microsecond,
+	NANO:   arrow.Nanosecond,
+}
+
+// arrowSchema returns the arrow schema of the SAResult, a timestamp column and a nullable float64
+// column for each request in request order. The requests are in the "csvdata.requests" metadata.
+func (result SAResult) arrowSchema(timeColumnName string, timeUnit string) (*arrow.Schema, error) {
+	colnames := result.ColumnNames()
+	if StringInSlice(timeColumnName, colnames) {
+		return nil, fmt.Errorf("time column name %s is also an output column", timeColumnName)
+	}
+	for _, colname := range colnames {
+		if _, err := result.outputColumn(colname); err != nil {
+			return nil, err
+		}
+	}
+	meta, err := result.requestsMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]arrow.Field, 0, len(colnames)+1)
+	fields = append(fields, arrow.Field{Name: timeColumnName, Type: &arrow.TimestampType{Unit: arrowTimeUnits[timeUnit], TimeZone: "UTC"}})
+	for _, colname := range colnames {
+		fields = append(fields, arrow.Field{Name: colname, Type: arrow.PrimitiveTypes.Float64, Nullable: true})
+	}
+	md := arrow.NewMetadata([]string{"csvdata.requests"}, []string{meta})
+	return arrow.NewSchema(fields, &md), nil
+}
+
+// writeRecords passes the rows of the SAResult to write as records of at most size rows,
+// zero size means one record. NaN values are null.
+func (result SAResult) writeRecords(schema *arrow.Schema, timeUnit string, size int, write func(arrow.Record) error) error {
+	numRows := len(*result.TimeStamp)
+	if size <= 0 || size > numRows {
+		size = numRows
+	}
+	builder := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer builder.Release()
+
+	for start := 0; start < numRows; start += size {
+		end := start + size
+		if end > numRows {
+			end = numRows
+		}
+
+		times := builder.Field(0).(*array.TimestampBuilder)
+		times.Reserve(end - start)
+		for _, dte := range (*result.TimeStamp)[start:end] {
+			times.UnsafeAppend(arrow.Timestamp(TimetoEpoch(dte, timeUnit)))
+		}
+		for i, field := range schema.Fields()[1:] {
+			values := *result.Columns[field.Name]
+			col := builder.Field(i + 1).(*array.Float64Builder)
+			col.Reserve(end - start)
+			for row := start; row < end; row++ {
+				if row >= len(values) || math.IsNaN(values[row]) {
+					col.AppendNull()
+				} else {
+					col.Append(values[row])
+				}
+			}
+		}
+
+		rec := builder.NewRecord()
+		err := write(rec)
+		rec.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteParquet writes the SAResult to w as an Apache Parquet file. The file has a timestamp column
+// and a nullable double column for each request in request order, NaN values are written as null.
+// The requests are stored as json in the "csvdata.requests" key value metadata.
+func (result SAResult) WriteParquet(w io.Writer, opts ParquetOptions) error {
+	if opts.TimeColumnName == "" {
+		opts.TimeColumnName = "time"
+	}
+	if opts.TimeUnit == "" {
+		opts.TimeUnit = MILLI
+	}
+	if !StringInSlice(opts.TimeUnit, []string{MILLI, MICRO, NANO}) {
+		return fmt.Errorf("parquet time unit %s is not valid", opts.TimeUnit)
+	}
+
+	schema, err := result.arrowSchema(opts.TimeColumnName, opts.TimeUnit)
+	if err != nil {
+		return err
+	}
+	// the parquet writer closes w when it is an io.Closer, it is left open like the other writers
+	writer, err := pqarrow.NewFileWriter(schema, struct{ io.Writer }{w}, parquet.NewWriterProperties(), pqarrow.DefaultWriterProps())
+	if err != nil {
+		return err
+	}
+
+	// every record is a row group
+	err = result.writeRecords(schema, opts.TimeUnit, opts.RowGroupSize, writer.Write)
+	if err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
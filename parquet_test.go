@@ -0,0 +1,108 @@
+package csvdata_test
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v11/arrow"
+	"github.com/apache/arrow/go/v11/arrow/array"
+	"github.com/apache/arrow/go/v11/arrow/memory"
+	"github.com/apache/arrow/go/v11/parquet/file"
+	"github.com/apache/arrow/go/v11/parquet/pqarrow"
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func TestWriteParquet(t *testing.T) {
+	rain := []float64{1.25, math.NaN(), 3}
+	temp := []float64{27, 26, 25}
+	timestamp := []time.Time{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+	requests := []csvdata.RequestColumnTable{
+		{InputColumnName: "AT_1200_Avg", OutputColumnName: "temp", Method: csvdata.MEAN, WindowString: "-23h59m59s_0h"},
+		{InputColumnName: "Rain_Tot", OutputColumnName: "rain", Method: csvdata.SUM},
+	}
+	result := csvdata.SAResult{
+		Columns:   csvdata.Columns{"rain": &rain, "temp": &temp},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+
+	var buf bytes.Buffer
+	if err := result.WriteParquet(&buf, csvdata.ParquetOptions{RowGroupSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+
+	// read it back with the parquet library
+	pf, err := file.NewParquetReader(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pf.NumRowGroups() != 2 || pf.NumRows() != 3 {
+		t.Errorf("got %d row groups and %d rows", pf.NumRowGroups(), pf.NumRows())
+	}
+	if meta := pf.MetaData().KeyValueMetadata().FindValue("csvdata.requests"); meta == nil || !bytes.Contains([]byte(*meta), []byte(`"method":"mean"`)) {
+		t.Error("request metadata is missing")
+	}
+	reader, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	table, err := reader.ReadTable(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer table.Release()
+
+	schema := table.Schema()
+	if len(schema.Fields()) != 3 || schema.Field(0).Name != "time" || schema.Field(1).Name != "temp" || schema.Field(2).Name != "rain" {
+		t.Fatalf("got schema %v", schema)
+	}
+	times := []time.Time{}
+	for _, chunk := range table.Column(0).Data().Chunks() {
+		for r := 0; r < chunk.Len(); r++ {
+			times = append(times, chunk.(*array.Timestamp).Value(r).ToTime(arrow.Millisecond))
+		}
+	}
+	if len(times) != len(timestamp) {
+		t.Fatalf("got %d times", len(times))
+	}
+	for r := range timestamp {
+		if !times[r].Equal(timestamp[r]) {
+			t.Errorf("row %d: got time %v, want %v", r, times[r], timestamp[r])
+		}
+	}
+	for c, want := range [][]float64{temp, rain} {
+		got := []float64{}
+		for _, chunk := range table.Column(c + 1).Data().Chunks() {
+			for r := 0; r < chunk.Len(); r++ {
+				// NaN is written as null
+				if chunk.IsNull(r) {
+					got = append(got, math.NaN())
+				} else {
+					got = append(got, chunk.(*array.Float64).Value(r))
+				}
+			}
+		}
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %v, want %v", schema.Field(c+1).Name, got, want)
+		}
+		for r := range want {
+			if got[r] != want[r] && !(math.IsNaN(got[r]) && math.IsNaN(want[r])) {
+				t.Errorf("%s: got %v, want %v", schema.Field(c+1).Name, got, want)
+				break
+			}
+		}
+	}
+
+	// the time column name must not collide with the output columns
+	if err := result.WriteParquet(&buf, csvdata.ParquetOptions{TimeColumnName: "rain"}); err == nil {
+		t.Error("expected error for the time column name")
+	}
+}
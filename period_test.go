@@ -0,0 +1,156 @@
+package csvdata_test
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+// writeHourly splits an example file in hourly files named 2006-01-02_15.csv in dir
+func writeHourly(t *testing.T, dir string, example string) {
+	lines := strings.Split(strings.TrimRight(string(readExample(t, example)), "\n"), "\n")
+	hours := map[string][]string{}
+	for _, line := range lines[1:] {
+		ts, err := strconv.ParseInt(line[:strings.Index(line, ",")], 10, 64)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := time.Unix(ts, 0).UTC().Format("2006-01-02_15.csv")
+		hours[name] = append(hours[name], line)
+	}
+	for name, rows := range hours {
+		content := lines[0] + "\n" + strings.Join(rows, "\n") + "\n"
+		writeFixture(t, filepath.Join(dir, name), []byte(content))
+	}
+}
+
+func TestCsvAggregateTable_Periods(t *testing.T) {
+	// the archive has daily files until 2023-01-11, then hourly files in another directory
+	root := fixtureDir(t)
+	writeFixture(t, filepath.Join(root, "daily", "2023-01-10.csv"), readExample(t, "2023-01-10.csv"))
+	writeHourly(t, filepath.Join(root, "hourly"), "2023-01-11.csv")
+
+	cfg := csvdata.CsvAggregateTableConfigs{
+		FileConfigs: []csvdata.FileConfig{
+			{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"},
+		},
+		Requests: []csvdata.RequestColumnTable{
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "first", Method: csvdata.FIRST},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "last", Method: csvdata.LAST},
+			{InputColumnName: "temperature_avg_60", OutputColumnName: "count", Method: csvdata.COUNT},
+		},
+		StartTime:     time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC),
+		EndTime:       time.Date(2023, 1, 11, 12, 0, 0, 0, time.UTC),
+		TimePrecision: csvdata.SECOND,
+		AggWindow:     "1h",
+	}
+	want, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change := time.Date(2023, 1, 11, 0, 0, 0, 0, time.UTC)
+	cfg.FileConfigs = []csvdata.FileConfig{{
+		Periods: []csvdata.FilePeriod{
+			{Until: change, FileNamingFormat: filepath.Join(root, "daily", "2006-01-02.csv"), FileFrequency: "24h"},
+			{From: change, FileNamingFormat: filepath.Join(root, "hourly", "2006-01-02_15.csv"), FileFrequency: "1h"},
+		},
+	}}
+	got, err := csvdata.CsvAggregateTable(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, col := range []string{"first", "last", "count"} {
+		for i, v := range *want.Columns[col] {
+			if (*got.Columns[col])[i] != v {
+				t.Errorf("%s row %d got %v, want %v", col, i, (*got.Columns[col])[i], v)
+			}
+		}
+	}
+	if (*got.Columns["count"])[20] == 0 {
+		t.Error("no rows read from the hourly files")
+	}
+
+	// the periods must not overlap
+	cfg.FileConfigs[0].Periods[1].From = change.Add(-time.Hour)
+	if _, err := csvdata.CsvAggregateTable(cfg); err == nil {
+		t.Error("expected an error for overlapping periods")
+	}
+}
+
+func TestPeriods_MidDay(t *testing.T) {
+	// the layout changes at 2023-01-10 12:00, the daily file of that day also has the rows of the afternoon
+	// and the hourly files also have the rows of the morning
+	root := fixtureDir(t)
+	for _, day := range []string{"2023-01-10.csv", "2023-01-11.csv"} {
+		writeFixture(t, filepath.Join(root, "daily", day), readExample(t, day))
+		writeHourly(t, filepath.Join(root, "hourly"), day)
+	}
+	change := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	periods := []csvdata.FileConfig{{
+		Periods: []csvdata.FilePeriod{
+			{Until: change, FileNamingFormat: filepath.Join(root, "daily", "2006-01-02.csv"), FileFrequency: "24h"},
+			{From: change, FileNamingFormat: filepath.Join(root, "hourly", "2006-01-02_15.csv"), FileFrequency: "1h"},
+		},
+	}}
+	plain := []csvdata.FileConfig{{FileNamingFormat: "./example/2006-01-02.csv", FileFrequency: "24h"}}
+
+	t.Run("Table", func(t *testing.T) {
+		cfg := csvdata.CsvAggregateTableConfigs{
+			FileConfigs: plain,
+			Requests: []csvdata.RequestColumnTable{
+				{InputColumnName: "temperature_avg_60", OutputColumnName: "count", Method: csvdata.COUNT},
+				{InputColumnName: "temperature_avg_60", OutputColumnName: "sum", Method: csvdata.SUM},
+			},
+			StartTime:     time.Date(2023, 1, 10, 3, 0, 0, 0, time.UTC),
+			EndTime:       time.Date(2023, 1, 10, 21, 0, 0, 0, time.UTC),
+			TimePrecision: csvdata.SECOND,
+			AggWindow:     "3h",
+		}
+		want, err := csvdata.CsvAggregateTable(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.FileConfigs = periods
+		got, err := csvdata.CsvAggregateTable(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, col := range []string{"count", "sum"} {
+			for i, v := range *want.Columns[col] {
+				if (*got.Columns[col])[i] != v {
+					t.Errorf("%s row %d got %v, want %v", col, i, (*got.Columns[col])[i], v)
+				}
+			}
+		}
+	})
+
+	t.Run("Point", func(t *testing.T) {
+		cfg := csvdata.CsvAggregatePointConfigs{
+			FileConfigs: plain,
+			Requests: []csvdata.RequestColumn{
+				{InputColumnName: "temperature_avg_60", OutputColumnName: "count", Method: csvdata.COUNT},
+				{InputColumnName: "temperature_avg_60", OutputColumnName: "sum", Method: csvdata.SUM},
+			},
+			StartTime:     time.Date(2023, 1, 10, 6, 0, 0, 0, time.UTC),
+			EndTime:       time.Date(2023, 1, 10, 18, 0, 0, 0, time.UTC),
+			TimePrecision: csvdata.SECOND,
+		}
+		want, err := csvdata.CsvAggregatePoint(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.FileConfigs = periods
+		got, err := csvdata.CsvAggregatePoint(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want["count"] == 0 || got["count"] != want["count"] || got["sum"] != want["sum"] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package csvdata
+
+import (
+	"fmt"
+	"math"
+)
+
+// pick modes
+const (
+	NEAREST  = "nearest"  // the nearest sample, the earlier one on tie
+	PREVIOUS = "previous" // the last sample at or before the pick time
+	NEXT     = "next"     // the first sample at or after the pick time
+	LINEAR   = "linear"   // linear interpolation between the samples on either side
+)
+
+// fill methods, PREVIOUS (forward fill), NEXT (backward fill), LINEAR and NEAREST are shared with the pick modes
+const (
+	NONE = "none"
+)
+
+// checkFill checks the fill method and parses the fill limit, an empty limit means no limit
+func checkFill(method string, limit string, precision string) (int64, error) {
+	if method == "" || method == NONE {
+		return 0, nil
+	}
+	if !StringInSlice(method, []string{NEAREST, PREVIOUS, NEXT, LINEAR}) {
+		return 0, fmt.Errorf("fill method %s is not valid", method)
+	}
+	if limit == "" {
+		return 0, nil
+	}
+	limitEp, err := DurationtoEpoch(limit, precision)
+	if err != nil {
+		return 0, fmt.Errorf("fill limit %s is not valid", limit)
+	}
+	if limitEp <= 0 {
+		return 0, fmt.Errorf("fill limit %s must be positive", limit)
+	}
+	return limitEp, nil
+}
+
+// checkPick checks the pick mode and parses the pick tolerance, an empty tolerance means no limit
+func checkPick(mode *string, tolerance string, precision string) (int64, error) {
+	if *mode == "" {
+		*mode = NEAREST
+	}
+	if !StringInSlice(*mode, []string{NEAREST, PREVIOUS, NEXT, LINEAR}) {
+		return 0, fmt.Errorf("pick mode %s is not valid", *mode)
+	}
+	if tolerance == "" {
+		return 0, nil
+	}
+	tolEp, err := DurationtoEpoch(tolerance, precision)
+	if err != nil {
+		return 0, fmt.Errorf("pick tolerance %s is not valid", tolerance)
+	}
+	if tolEp <= 0 {
+		return 0, fmt.Errorf("pick tolerance %s must be positive", tolerance)
+	}
+	return tolEp, nil
+}
+
+// pickValue resolves a pick from the sample at or before the pick epoch and the sample at or after it,
+// either may be nil. toleranceEp is the maximum distance to a used sample, zero means no limit.
+// It returns the value and the epoch of the used sample, the epoch is the pick epoch when interpolated.
+func pickValue(mode string, pickEp int64, toleranceEp int64, prev *Input, next *Input) (float64, int64, bool) {
+	within := func(in *Input) bool {
+		if in == nil {
+			return false
+		}
+		if toleranceEp == 0 {
+			return true
+		}
+		dist := in.Epoch - pickEp
+		if dist < 0 {
+			dist = -dist
+		}
+		return dist <= toleranceEp
+	}
+	if !within(prev) {
+		prev = nil
+	}
+	if !within(next) {
+		next = nil
+	}
+
+	switch mode {
+	case PREVIOUS:
+		if prev != nil {
+			return prev.Value, prev.Epoch, true
+		}
+	case NEXT:
+		if next != nil {
+			return next.Value, next.Epoch, true
+		}
+	case LINEAR:
+		switch {
+		case prev != nil && prev.Epoch == pickEp:
+			return prev.Value, prev.Epoch, true
+		case next != nil && next.Epoch == pickEp:
+			return next.Value, next.Epoch, true
+		case prev != nil && next != nil:
+			frac := float64(pickEp-prev.Epoch) / float64(next.Epoch-prev.Epoch)
+			return prev.Value + frac*(next.Value-prev.Value), pickEp, true
+		}
+	default:
+		switch {
+		case prev != nil && next != nil:
+			if next.Epoch-pickEp < pickEp-prev.Epoch {
+				return next.Value, next.Epoch, true
+			}
+			return prev.Value, prev.Epoch, true
+		case prev != nil:
+			return prev.Value, prev.Epoch, true
+		case next != nil:
+			return next.Value, next.Epoch, true
+		}
+	}
+	return math.NaN(), 0, false
+}
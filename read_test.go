@@ -1,11 +1,16 @@
 package csvdata_test
 
 import (
+	"bufio"
 	"encoding/csv"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
+
+	"github.com/luhtfiimanal/csvdata"
 )
 
 func BenchmarkReadCSVLineByLine(b *testing.B) {
@@ -92,3 +97,51 @@ func BenchmarkReadCSVConcurrentlyLineByLine(b *testing.B) {
 		wg.Wait()
 	}
 }
+
+// the columns of a typical request, the old readers parsed every field of the row with csv.Reader
+var benchColumns = []string{"dewpoint_avg_60", "ev_water_temperature_avg_60"}
+
+func BenchmarkReadCSVTwoColumns(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		csvfile, _ := os.Open("example/2023-01-10.csv")
+
+		reader := csv.NewReader(csvfile)
+		header, _ := reader.Read()
+		cols := []int{}
+		for _, name := range benchColumns {
+			for j, col := range header {
+				if col == name {
+					cols = append(cols, j)
+				}
+			}
+		}
+
+		for {
+			line, err := reader.Read()
+			if err != nil {
+				break
+			}
+			_, _ = strconv.ParseInt(line[0], 10, 64)
+			for _, j := range cols {
+				_, _ = strconv.ParseFloat(line[j], 64)
+			}
+		}
+		csvfile.Close()
+	}
+}
+
+func BenchmarkReadCSVProjectedTwoColumns(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		csvfile, _ := os.Open("example/2023-01-10.csv")
+
+		br := bufio.NewReader(csvfile)
+		headerLine, _ := br.ReadString('\n')
+		header, _ := csv.NewReader(strings.NewReader(headerLine)).Read()
+		if _, err := csvdata.CountProjected(br, header, benchColumns); err != nil {
+			b.Fatal(err)
+		}
+		csvfile.Close()
+	}
+}
@@ -2,58 +2,141 @@ package csvdata
 
 import (
 	"bytes"
-	"encoding/csv"
 	"fmt"
 	"math"
 	"os"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
 )
 
 type SmartAggregator struct {
-	Agg    string
-	Data   chan Input
-	Column *SAColumn
+	Agg     string
+	Data    chan Input
+	Batches chan []Input // used instead of Data when it is set, the values are sent in batches
+	Column  *SAColumn
+	batch   []Input // the rest of the current batch
 }
 
+// values per batch sent by the table readers
+const inputBatchSize = 256
+
 type SAColumn struct {
 	OutputColumnName string
 	TimeResultEp     *[]int64
 	PickRelativeEp   int64
 	PickRelative     []int64
+	PickMode         string // NEAREST, PREVIOUS, NEXT or LINEAR, empty means NEAREST
+	PickToleranceEp  int64  // maximum distance to a picked sample, zero means no limit
+	FillMethod       string // NONE, PREVIOUS, NEXT, LINEAR or NEAREST, empty means NONE
+	FillLimitEp      int64  // maximum distance to an observed value used to fill, zero means no limit
+	Filled           []bool // true for the results that are filled, only set when FillMethod is used
 	WindowRelativeEp [2]int64
 	WindowRelative   [][2]int64
 	Result           []float64
+	TimeStartEp      int64 // first result epoch, the grid is TimeStartEp + i*TimeStepEp for i < TimeCount when TimeResultEp is nil
+	TimeStepEp       int64
+	TimeCount        int
+
+	out   chan<- saOutput // receives every result as soon as it is final, set by the stream
+	index int             // position of the column in the stream row
 }
 
-func (sac *SAColumn) makeWindow() {
-	// check if WindowRelative is not set
-	if len(sac.WindowRelative) == 0 {
-		// if WindowRelativeEp is set, make WindowRelative based on relative with TimeResultEp
-		sac.WindowRelative = make([][2]int64, len(*sac.TimeResultEp))
-		for i, v := range *sac.TimeResultEp {
-			sac.WindowRelative[i] = [2]int64{v + sac.WindowRelativeEp[0], v + sac.WindowRelativeEp[1]}
-		}
+// saOutput is one result sent to the stream as soon as its window is closed
+type saOutput struct {
+	column int
+	index  int
+	value  float64
+}
+
+// resultLen returns the number of results on the grid
+func (sac *SAColumn) resultLen() int {
+	if sac.TimeResultEp != nil {
+		return len(*sac.TimeResultEp)
 	}
+	return sac.TimeCount
 }
 
-func (sac *SAColumn) makePickRelative() {
-	// check if PickRelative is not set
-	if len(sac.PickRelative) == 0 {
-		// if PickRelativeEp is set, make PickRelative based on relative with TimeResultEp
-		sac.PickRelative = make([]int64, len(*sac.TimeResultEp))
-		for i, v := range *sac.TimeResultEp {
-			sac.PickRelative[i] = v + sac.PickRelativeEp
-		}
+// timeAt returns the epoch of the result i
+func (sac *SAColumn) timeAt(i int) int64 {
+	if sac.TimeResultEp != nil {
+		return (*sac.TimeResultEp)[i]
+	}
+	return sac.TimeStartEp + int64(i)*sac.TimeStepEp
+}
+
+// windowLen returns the number of windows, WindowRelative is used when it is set
+func (sac *SAColumn) windowLen() int {
+	if len(sac.WindowRelative) > 0 {
+		return len(sac.WindowRelative)
+	}
+	return sac.resultLen()
+}
+
+// windowAt returns the window of the result i, relative to the result time when WindowRelative is not set
+func (sac *SAColumn) windowAt(i int) [2]int64 {
+	if len(sac.WindowRelative) > 0 {
+		return sac.WindowRelative[i]
+	}
+	t := sac.timeAt(i)
+	return [2]int64{t + sac.WindowRelativeEp[0], t + sac.WindowRelativeEp[1]}
+}
+
+// pickLen returns the number of picks, PickRelative is used when it is set
+func (sac *SAColumn) pickLen() int {
+	if len(sac.PickRelative) > 0 {
+		return len(sac.PickRelative)
+	}
+	return sac.resultLen()
+}
+
+// pickAt returns the pick epoch of the result i, relative to the result time when PickRelative is not set
+func (sac *SAColumn) pickAt(i int) int64 {
+	if len(sac.PickRelative) > 0 {
+		return sac.PickRelative[i]
+	}
+	return sac.timeAt(i) + sac.PickRelativeEp
+}
+
+// save stores the result i, and sends it to the stream if there is one
+func (sac *SAColumn) save(i int, v float64) {
+	if i < len(sac.Result) {
+		sac.Result[i] = v
+	}
+	if sac.out != nil {
+		sac.out <- saOutput{column: sac.index, index: i, value: v}
 	}
 }
 
 func (sa *SmartAggregator) drainChannel() {
+	if sa.Batches != nil {
+		for range sa.Batches {
+		}
+		return
+	}
 	for range sa.Data {
 	}
 }
 
+// next returns the next value from Data or Batches, false when the channel is closed
+func (sa *SmartAggregator) next() (Input, bool) {
+	if sa.Batches == nil {
+		val, ok := <-sa.Data
+		return val, ok
+	}
+	for len(sa.batch) == 0 {
+		batch, ok := <-sa.Batches
+		if !ok {
+			return Input{}, false
+		}
+		sa.batch = batch
+	}
+	val := sa.batch[0]
+	sa.batch = sa.batch[1:]
+	return val, true
+}
+
 func NewSmartAggregator(agg string, col *SAColumn, wg *sync.WaitGroup) *SmartAggregator {
 	sa := &SmartAggregator{
 		Agg:    agg,
@@ -66,269 +149,307 @@ func NewSmartAggregator(agg string, col *SAColumn, wg *sync.WaitGroup) *SmartAgg
 	return sa
 }
 
+// NewSmartAggregatorBatch is NewSmartAggregator receiving the values in batches through Batches
+func NewSmartAggregatorBatch(agg string, col *SAColumn, wg *sync.WaitGroup) *SmartAggregator {
+	sa := &SmartAggregator{
+		Agg:     agg,
+		Batches: make(chan []Input, 4),
+		Column:  col,
+	}
+
+	go sa.Do(wg)
+
+	return sa
+}
+
+// inputBatcher collects the values sent to several aggregators and sends them in batches
+type inputBatcher struct {
+	aggs    []*SmartAggregator
+	batches [][]Input
+}
+
+func newInputBatcher(aggs []*SmartAggregator) *inputBatcher {
+	return &inputBatcher{aggs: aggs, batches: make([][]Input, len(aggs))}
+}
+
+// add adds a value for the aggregator i, the batch is sent when it is full
+func (ib *inputBatcher) add(i int, val Input) {
+	if ib.batches[i] == nil {
+		ib.batches[i] = make([]Input, 0, inputBatchSize)
+	}
+	ib.batches[i] = append(ib.batches[i], val)
+	if len(ib.batches[i]) == inputBatchSize {
+		ib.aggs[i].Batches <- ib.batches[i]
+		ib.batches[i] = nil
+	}
+}
+
+// flush sends the batches that are not full
+func (ib *inputBatcher) flush() {
+	for i, batch := range ib.batches {
+		if len(batch) > 0 {
+			ib.aggs[i].Batches <- batch
+		}
+		ib.batches[i] = nil
+	}
+}
+
 func (sa *SmartAggregator) Do(wg *sync.WaitGroup) {
 	defer wg.Done()
 	switch sa.Agg {
 	case SUM:
-		sa.Column.makeWindow()
 		sa.doSumCountMean(sa.Agg)
 	case COUNT:
-		sa.Column.makeWindow()
 		sa.doSumCountMean(sa.Agg)
 	case MEAN:
-		sa.Column.makeWindow()
 		sa.doSumCountMean(sa.Agg)
 	case MAX:
-		sa.Column.makeWindow()
 		sa.doMinMax(sa.Agg)
 	case MIN:
-		sa.Column.makeWindow()
 		sa.doMinMax(sa.Agg)
 	case FIRST:
-		sa.Column.makeWindow()
 		sa.doFirst()
 	case LAST:
-		sa.Column.makeWindow()
 		sa.doLast()
 	case PICK:
-		sa.Column.makePickRelative()
 		sa.doPick()
+	case RESAMPLE:
+		sa.doResample()
 	}
-}
-func (sa *SmartAggregator) doSumCountMean(agg string) {
-	// mmake all result nan
-	if agg == SUM || agg == MEAN {
-		for i := range sa.Column.Result {
-			sa.Column.Result[i] = math.NaN()
-		}
-	}
-	var sum float64
-	var count float64
 
-	savefunc := func(i int) {
-		// do the last calculation
-		switch agg {
-		case SUM:
-			if count != 0 {
-				sa.Column.Result[i] = sum
-			} else {
-				sa.Column.Result[i] = math.NaN()
-			}
-		case COUNT:
-			sa.Column.Result[i] = count
-		case MEAN:
-			if count != 0 {
-				sa.Column.Result[i] = sum / count
-			} else {
-				sa.Column.Result[i] = math.NaN()
-			}
-		}
+	// fill the gaps of the result
+	if sa.Column.FillMethod != "" && sa.Column.FillMethod != NONE {
+		sa.Column.fill()
 	}
+}
 
-	windowi := 0
-	window := sa.Column.WindowRelative[windowi]
+// windowState is the running state of one open window
+type windowState struct {
+	sum   float64
+	count float64
+	value float64 // min, max, first, last or resampled value
+	epoch int64   // epoch of the resampled value
+	set   bool
+}
+
+// doWindows feeds every value to all the open windows that contain it. The windows may overlap,
+// so one value can be used by several windows, but they must be sorted by start and by end.
+// save is called exactly once for every window, in window order. A NaN value is a missing value,
+// it is not added but it closes the windows that end before it.
+func (sa *SmartAggregator) doWindows(add func(i int, st *windowState, val Input), save func(i int, st *windowState)) {
+	n := sa.Column.windowLen()
+	// active holds the state of the windows from lo to hi-1
+	active := []windowState{}
+	lo, hi := 0, 0
 
 channelloop:
 	for {
-		val, ok := <-sa.Data
+		val, ok := sa.next()
 		// check if channel is closed
 		if !ok {
-			savefunc(windowi)
-			// break the outer loop
 			break channelloop
 		}
 
-		// check if the data is in the window
-		if val.Epoch >= window[0] && val.Epoch <= window[1] {
-			// add the value to the sum
-			sum += val.Value
-			count++
-		} else if val.Epoch > window[1] {
-			// remember the value
-			savefunc(windowi)
-			// loop through the windows until the epoch is less than the window[1]
-			for val.Epoch > window[1] {
-				windowi++
-				if windowi >= len(sa.Column.WindowRelative) {
-					break channelloop
-				}
-				window = sa.Column.WindowRelative[windowi]
+		// close the windows that end before the value
+		for lo < n && sa.Column.windowAt(lo)[1] < val.Epoch {
+			if lo < hi {
+				save(lo, &active[0])
+				active = active[1:]
+			} else {
+				// the window was never opened
+				save(lo, &windowState{})
 			}
-			// check if the data is in the window
+			lo++
+		}
+		if lo >= n {
+			break channelloop
+		}
+		if hi < lo {
+			hi = lo
+		}
+		if val.tick {
+			continue channelloop
+		}
+
+		// open the windows that start at or before the value
+		for hi < n && sa.Column.windowAt(hi)[0] <= val.Epoch {
+			active = append(active, windowState{})
+			hi++
+		}
+
+		// add the value to every open window containing it
+		for i := lo; i < hi; i++ {
+			window := sa.Column.windowAt(i)
 			if val.Epoch >= window[0] && val.Epoch <= window[1] {
-				// add the value to the sum
-				sum = val.Value
-				count = 1
-			} else {
-				// reset the sum and count
-				sum = 0
-				count = 0
+				add(i, &active[i-lo], val)
 			}
 		}
 	}
+
+	// save the rest of the windows
+	for ; lo < n; lo++ {
+		if lo < hi {
+			save(lo, &active[0])
+			active = active[1:]
+		} else {
+			save(lo, &windowState{})
+		}
+	}
+
 	// drain the channel
 	sa.drainChannel()
 }
 
-func (sa *SmartAggregator) doMinMax(minMax string) {
-	// mmake all result nan
-	for i := range sa.Column.Result {
-		sa.Column.Result[i] = math.NaN()
-	}
-	var result float64
-	if minMax == "min" {
-		result = math.MaxFloat64
-	} else if minMax == "max" {
-		result = -math.MaxFloat64
+func (sa *SmartAggregator) doSumCountMean(agg string) {
+	add := func(_ int, st *windowState, val Input) {
+		st.sum += val.Value
+		st.count++
 	}
 
-	savefunc := func(i int) {
+	save := func(i int, st *windowState) {
 		// do the last calculation
-		if result == -math.MaxFloat64 || result == math.MaxFloat64 {
-			sa.Column.Result[i] = math.NaN()
-		} else {
-			sa.Column.Result[i] = result
+		switch agg {
+		case SUM:
+			if st.count != 0 {
+				sa.Column.save(i, st.sum)
+			} else {
+				sa.Column.save(i, math.NaN())
+			}
+		case COUNT:
+			sa.Column.save(i, st.count)
+		case MEAN:
+			if st.count != 0 {
+				sa.Column.save(i, st.sum/st.count)
+			} else {
+				sa.Column.save(i, math.NaN())
+			}
 		}
 	}
 
-	windowi := 0
-	window := sa.Column.WindowRelative[windowi]
+	sa.doWindows(add, save)
+}
 
-channelloop:
-	for {
-		val, ok := <-sa.Data
-		// check if channel is closed
-		if !ok {
-			savefunc(windowi)
-			// break the outer loop
-			break channelloop
+func (sa *SmartAggregator) doMinMax(minMax string) {
+	add := func(_ int, st *windowState, val Input) {
+		// process the min or max
+		if !st.set || (minMax == MIN && val.Value < st.value) || (minMax == MAX && val.Value > st.value) {
+			st.value = val.Value
+			st.set = true
 		}
+	}
 
-		// check if the data is in the window
-		if val.Epoch >= window[0] && val.Epoch <= window[1] {
-			// process the min or max
-			if minMax == "min" && val.Value < result {
-				result = val.Value
-			} else if minMax == "max" && val.Value > result {
-				result = val.Value
-			}
-		} else if val.Epoch > window[1] {
-			// remember the value
-			savefunc(windowi)
-
-			// loop through the windows until the epoch is less than the window[1]
-			for val.Epoch > window[1] {
-				windowi++
-				if windowi >= len(sa.Column.WindowRelative) {
-					break channelloop
-				}
-				window = sa.Column.WindowRelative[windowi]
-			}
-			// check if the data is in the window
-			if val.Epoch >= window[0] && val.Epoch <= window[1] {
-				// add the value to the sum
-				result = val.Value
-			} else {
-				// if not in the next window, reset the max or min
-				if minMax == "min" {
-					result = math.MaxFloat64
-				} else if minMax == "max" {
-					result = -math.MaxFloat64
-				}
-			}
+	save := func(i int, st *windowState) {
+		if st.set {
+			sa.Column.save(i, st.value)
+		} else {
+			sa.Column.save(i, math.NaN())
 		}
-
 	}
-	// drain the channel
-	sa.drainChannel()
+
+	sa.doWindows(add, save)
 }
 
 func (sa *SmartAggregator) doFirst() {
-	// mmake all result nan
-	for i := range sa.Column.Result {
-		sa.Column.Result[i] = math.NaN()
+	add := func(_ int, st *windowState, val Input) {
+		// store only the first value of the window
+		if !st.set {
+			st.value = val.Value
+			st.set = true
+		}
 	}
 
-	windowi := 0
-	window := sa.Column.WindowRelative[windowi]
-
-	// Loop through the window
-channelloop:
-	for {
-		val, ok := <-sa.Data
-		// Check if channel is closed
-		if !ok {
-			break channelloop
+	save := func(i int, st *windowState) {
+		if st.set {
+			sa.Column.save(i, st.value)
+		} else {
+			sa.Column.save(i, math.NaN())
 		}
+	}
 
-		// Check if the data is in the window
-		if val.Epoch >= window[0] && val.Epoch <= window[1] {
-			// store the first value and move to the next window
-			sa.Column.Result[windowi] = val.Value
-			windowi++
-			if windowi >= len(sa.Column.WindowRelative) {
-				break channelloop
-			}
-			window = sa.Column.WindowRelative[windowi]
-		} else if val.Epoch > window[1] {
-			// loop through the windows until the epoch is in the window
-			for val.Epoch > window[1] {
-				windowi++
-				if windowi >= len(sa.Column.WindowRelative) {
-					break channelloop
-				}
-				window = sa.Column.WindowRelative[windowi]
-			}
-			// store the first value and move to the next window
-			sa.Column.Result[windowi] = val.Value
-			windowi++
-			if windowi >= len(sa.Column.WindowRelative) {
-				break channelloop
-			}
-			window = sa.Column.WindowRelative[windowi]
+	sa.doWindows(add, save)
+}
+
+func (sa *SmartAggregator) doLast() {
+	add := func(_ int, st *windowState, val Input) {
+		// store the last value of the window
+		st.value = val.Value
+		st.set = true
+	}
+
+	save := func(i int, st *windowState) {
+		if st.set {
+			sa.Column.save(i, st.value)
+		} else {
+			sa.Column.save(i, math.NaN())
 		}
 	}
-	// drain the channel
-	sa.drainChannel()
+
+	sa.doWindows(add, save)
 }
 
-func (sa *SmartAggregator) doLast() {
-	// mmake all result nan
-	for i := range sa.Column.Result {
-		sa.Column.Result[i] = math.NaN()
+func (sa *SmartAggregator) doResample() {
+	add := func(i int, st *windowState, val Input) {
+		// keep the value nearest to the result time
+		t := sa.Column.timeAt(i)
+		if !st.set || absEpoch(val.Epoch-t) < absEpoch(st.epoch-t) {
+			st.value = val.Value
+			st.epoch = val.Epoch
+			st.set = true
+		}
+	}
+
+	save := func(i int, st *windowState) {
+		if st.set {
+			sa.Column.save(i, st.value)
+		} else {
+			sa.Column.save(i, math.NaN())
+		}
 	}
 
-	windowi := 0
-	window := sa.Column.WindowRelative[windowi]
+	sa.doWindows(add, save)
+}
 
-	// Loop through the window
-channelloop:
-	for {
-		val, ok := <-sa.Data
-		// Check if channel is closed
-		if !ok {
-			break channelloop
+// fill fills the NaN results from the observed results around them
+func (sac *SAColumn) fill() {
+	sac.Filled = make([]bool, len(sac.Result))
+
+	// next[i] is the index of the first observed result at or after i
+	next := make([]int, len(sac.Result)+1)
+	next[len(sac.Result)] = -1
+	for i := len(sac.Result) - 1; i >= 0; i-- {
+		if !math.IsNaN(sac.Result[i]) {
+			next[i] = i
+		} else {
+			next[i] = next[i+1]
 		}
+	}
 
-		// Check if the data is in the window
-		if val.Epoch >= window[0] && val.Epoch <= window[1] {
-			// store the last value
-			sa.Column.Result[windowi] = val.Value
-		} else if val.Epoch > window[1] {
-			// loop through the windows until the epoch is in the window
-			for val.Epoch > window[1] {
-				windowi++
-				if windowi >= len(sa.Column.WindowRelative) {
-					break channelloop
-				}
-				window = sa.Column.WindowRelative[windowi]
-			}
-			// store the first value and move to the next window
-			sa.Column.Result[windowi] = val.Value
+	prev := -1
+	for i, v := range sac.Result {
+		if !math.IsNaN(v) {
+			prev = i
+			continue
+		}
+
+		var previn, nextin *Input
+		if prev >= 0 {
+			previn = &Input{Epoch: sac.timeAt(prev), Value: sac.Result[prev]}
+		}
+		if next[i] >= 0 {
+			nextin = &Input{Epoch: sac.timeAt(next[i]), Value: sac.Result[next[i]]}
+		}
+		if filled, _, ok := pickValue(sac.FillMethod, sac.timeAt(i), sac.FillLimitEp, previn, nextin); ok {
+			sac.Result[i] = filled
+			sac.Filled[i] = true
 		}
 	}
-	// drain the channel
-	sa.drainChannel()
+}
+
+func absEpoch(ep int64) int64 {
+	if ep < 0 {
+		return -ep
+	}
+	return ep
 }
 
 func (sa *SmartAggregator) doPick() {
@@ -336,88 +457,61 @@ func (sa *SmartAggregator) doPick() {
 	for i := range sa.Column.Result {
 		sa.Column.Result[i] = math.NaN()
 	}
-	var lenlast float64 = math.MaxFloat64
-	var leniter float64 = math.MaxFloat64
-	// var leniterabs int64 = math.MaxInt64
-	// var leniternext int64 = math.MaxInt64
-	var pick float64 = math.NaN()
-	var pickenow float64
-	var pickenext float64
-	var pseudoWindow0 float64
-	var pseudoWindow1 float64
-
-	pickprocess := func(i int) {
-		pickenow = float64(sa.Column.PickRelative[i])
-		// get next pick epoch
-		if i+1 < len(sa.Column.PickRelative) {
-			pickenext = float64(sa.Column.PickRelative[i+1])
-			pseudoWindow0 = pseudoWindow1
-			pseudoWindow1 = float64(pickenext+pickenow) / 2
-		} else {
-			pickenext = math.MaxInt64
-			pseudoWindow0 = pseudoWindow1
-			pseudoWindow1 = math.MaxInt64
-		}
-		pick = math.NaN()
-		lenlast = math.MaxFloat64
+	n := sa.Column.pickLen()
+
+	save := func(i int, prev *Input, next *Input) {
+		v, _, _ := pickValue(sa.Column.PickMode, sa.Column.pickAt(i), sa.Column.PickToleranceEp, prev, next)
+		sa.Column.save(i, v)
 	}
 
+	// prev is the last sample before the current one, it is at or before every pick not saved yet
+	var prev *Input
 	picki := 0
-	// get next pick epoch
-	pickprocess(picki)
 
 channelloop:
 	for {
-		val, ok := <-sa.Data
+		val, ok := sa.next()
 		if !ok {
-			// save the last pick
-			sa.Column.Result[picki] = pick
 			break channelloop
 		}
-
-		epochi := float64(val.Epoch)
-
-		// check if the epoch is within the window
-		if epochi >= pseudoWindow0 && epochi <= pseudoWindow1 {
-
-			// len check, len is absolute value of the difference between the epoch and the pick epoch
-			leniter = math.Abs(epochi - pickenow)
-
-			// if leniter is 0 pick the value and break channel loop
-			if leniter == 0 {
-				sa.Column.Result[picki] = val.Value
-				// move to the next pick
-				picki++
-				if picki >= len(sa.Column.PickRelative) {
-					break channelloop
+		cur := val
+
+		// a tick only tells that no sample comes before it, so the picks
+		// are final when they do not need a next sample or it is too far away
+		if cur.tick {
+			for picki < n {
+				pick := sa.Column.pickAt(picki)
+				tolerance := sa.Column.PickToleranceEp
+				if pick >= cur.Epoch || (sa.Column.PickMode != PREVIOUS && (tolerance == 0 || cur.Epoch <= pick+tolerance)) {
+					break
 				}
-				pickprocess(picki)
-				// reset values
-				lenlast = math.MaxFloat64
-				leniter = math.MaxFloat64
-				pick = math.NaN()
-				continue channelloop
-			}
-
-			// if leniter is smaller than lenlast, save the pick
-			if leniter < lenlast {
-				pick = val.Value
-				lenlast = leniter
-			}
-
-		} else if epochi > pseudoWindow1 {
-			// save the last pick
-			sa.Column.Result[picki] = pick
-			// move until the epoch is in the window
-			for epochi > pseudoWindow1 {
+				save(picki, prev, nil)
 				picki++
-				if picki >= len(sa.Column.PickRelative) {
-					break channelloop
-				}
-				pickprocess(picki)
 			}
+			continue channelloop
+		}
+
+		// the picks before the sample are between prev and the sample
+		for picki < n && sa.Column.pickAt(picki) < cur.Epoch {
+			save(picki, prev, &cur)
+			picki++
+		}
+		// the picks exactly at the sample
+		for picki < n && sa.Column.pickAt(picki) == cur.Epoch {
+			save(picki, &cur, &cur)
+			picki++
+		}
+		if picki >= n {
+			break channelloop
 		}
+		prev = &cur
 	}
+
+	// the rest of the picks have no next sample
+	for ; picki < n; picki++ {
+		save(picki, prev, nil)
+	}
+
 	// drain the channel
 	sa.drainChannel()
 }
@@ -430,11 +524,18 @@ type SAMap map[string]*SmartAggregator
 func (sm SAMap) SAMapToStruct(timePrecision string) SAResult {
 	var timeResultEp *[]int64
 	resultMap := make(Columns)
+	var filled FillFlags
 	for _, v := range sm {
 		if timeResultEp == nil {
 			timeResultEp = v.Column.TimeResultEp
 		}
 		resultMap[v.Column.OutputColumnName] = &v.Column.Result
+		if v.Column.Filled != nil {
+			if filled == nil {
+				filled = make(FillFlags)
+			}
+			filled[v.Column.OutputColumnName] = &v.Column.Filled
+		}
 	}
 
 	// convert timeResultEp to time.Time
@@ -444,6 +545,7 @@ func (sm SAMap) SAMapToStruct(timePrecision string) SAResult {
 	}
 	return SAResult{
 		Columns:   resultMap,
+		Filled:    filled,
 		TimeStamp: &timeResult,
 	}
 }
@@ -452,10 +554,41 @@ func (sm SAMap) SAMapToStruct(timePrecision string) SAResult {
 // Output map of SmartAggregator
 type Columns map[string]*[]float64
 
+// Per cell flags of the filled columns, true when the value is filled and not observed
+type FillFlags map[string]*[]bool
+
 type SAResult struct {
 	Columns
-	Requests  *[]RequestColumnTable // it is necessary to save the request when we need to convert it to csv, because without it the order of the columns will be random
-	TimeStamp *[]time.Time
+	Requests   *[]RequestColumnTable // it is necessary to save the request when we need to convert it to csv, because without it the order of the columns will be random
+	Computed   *[]RequestComputedColumn
+	Filled     FillFlags // only the columns with a FillMethod
+	TimeStamp  *[]time.Time
+	JSONLayout string // COLUMNS or ROWS, layout used by MarshalJSON, empty means COLUMNS
+
+	UnsortedFiles []string       // files whose rows are not in time order, they are sorted in runs before the aggregation
+	DroppedRows   map[string]int // rows that could not be put in time order by file, before the rows already aggregated
+}
+
+// ColumnNames returns the output column names in request order, computed columns come last
+func (result SAResult) ColumnNames() []string {
+	names := []string{}
+	if result.Requests == nil {
+		// no request saved, use the sorted column names
+		for key := range result.Columns {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+		return names
+	}
+	for i := range *result.Requests {
+		names = append(names, (*result.Requests)[i].OutputColumnName)
+	}
+	if result.Computed != nil {
+		for i := range *result.Computed {
+			names = append(names, (*result.Computed)[i].OutputColumnName)
+		}
+	}
+	return names
 }
 
 // SaveToCSV saves the SAResult to a csv file
@@ -466,41 +599,16 @@ func (result SAResult) SaveToCSV(filename string) error {
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	return result.WriteCSV(file, NewCSVWriterOptions())
+}
 
-	// Writing the header
-	headers := []string{"timeResultEp"}
-	for i := range *result.Requests {
-		colname := (*result.Requests)[i].OutputColumnName
-		headers = append(headers, colname)
-	}
-	if err := writer.Write(headers); err != nil {
-		return err
+// outputColumn returns the values of an output column, an error when the result has no such column
+func (result SAResult) outputColumn(key string) ([]float64, error) {
+	values, ok := result.Columns[key]
+	if !ok || values == nil {
+		return nil, fmt.Errorf("output column %s is not in the result", key)
 	}
-
-	// Writing values
-	for idx, dte := range *result.TimeStamp {
-		line := make([]string, len(result.Columns)+1)
-		line[0] = dte.UTC().Format(time.DateTime)
-
-		col := 1
-		for col < len(line) {
-			// get value from the column using header
-			colname := headers[col]
-			resValues := result.Columns[colname]
-			if idx < len(*resValues) {
-				line[col] = strconv.FormatFloat((*resValues)[idx], 'f', -1, 64)
-			} else {
-				line[col] = ""
-			}
-			col++
-		}
-		if err := writer.Write(line); err != nil {
-			return err
-		}
-	}
-	return nil
+	return *values, nil
 }
 
 // JSON5 output of SAResult
@@ -509,15 +617,20 @@ func (result SAResult) ToJson5() ([]byte, error) {
 	buf.WriteString(`{"Columns":{`)
 
 	// Write results
-	first := true
-	for key, arr := range result.Columns {
-		if !first {
+	for keyi, key := range result.ColumnNames() {
+		arr, err := result.outputColumn(key)
+		if err != nil {
+			return nil, err
+		}
+		if keyi != 0 {
 			buf.WriteString(",")
 		}
-		first = false
 
-		buf.WriteString(fmt.Sprintf(`"%s":[`, key))
-		for i, val := range *arr {
+		if err := writeJSONValue(&buf, key); err != nil {
+			return nil, err
+		}
+		buf.WriteString(":[")
+		for i, val := range arr {
 			if i != 0 {
 				buf.WriteString(",")
 			}
@@ -2,6 +2,7 @@ package csvdata_test
 
 import (
 	"fmt"
+	"math"
 	"sync"
 	"testing"
 
@@ -199,3 +200,180 @@ func TestSmartAggregator(t *testing.T) {
 		})
 	}
 }
+
+func TestSmartAggregatorOverlap(t *testing.T) {
+	inputs := []csvdata.Input{}
+	for i := int64(0); i <= 10; i++ {
+		inputs = append(inputs, csvdata.Input{Epoch: i, Value: float64(i)})
+	}
+	// window length 4 with step 2, every value is used by two windows
+	timeResultEp := []int64{3, 5, 7, 9, 14}
+	windowrelative := [][2]int64{{0, 3}, {2, 5}, {4, 7}, {6, 9}, {11, 14}}
+	tests := []struct {
+		name string
+		agg  string
+		want []float64
+	}{
+		{"SUM", csvdata.SUM, []float64{6, 14, 22, 30, math.NaN()}},
+		{"COUNT", csvdata.COUNT, []float64{4, 4, 4, 4, 0}},
+		{"MEAN", csvdata.MEAN, []float64{1.5, 3.5, 5.5, 7.5, math.NaN()}},
+		{"MAX", csvdata.MAX, []float64{3, 5, 7, 9, math.NaN()}},
+		{"MIN", csvdata.MIN, []float64{0, 2, 4, 6, math.NaN()}},
+		{"FIRST", csvdata.FIRST, []float64{0, 2, 4, 6, math.NaN()}},
+		{"LAST", csvdata.LAST, []float64{3, 5, 7, 9, math.NaN()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			wg.Add(1)
+
+			reqcolumn := csvdata.SAColumn{
+				OutputColumnName: tt.agg,
+				WindowRelative:   windowrelative,
+				TimeResultEp:     &timeResultEp,
+				Result:           make([]float64, len(timeResultEp)),
+			}
+			sa := csvdata.NewSmartAggregator(tt.agg, &reqcolumn, &wg)
+
+			go func() {
+				for _, d := range inputs {
+					sa.Data <- d
+				}
+				close(sa.Data)
+			}()
+
+			wg.Wait()
+
+			for i, v := range sa.Column.Result {
+				if v != tt.want[i] && !(math.IsNaN(v) && math.IsNaN(tt.want[i])) {
+					t.Errorf("window %d got %v, want %v", i, v, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDataPickMode(t *testing.T) {
+	// sparse data, a sample every 10
+	data := []csvdata.Input{
+		{Epoch: 0, Value: 0},
+		{Epoch: 10, Value: 10},
+		{Epoch: 20, Value: 30},
+		{Epoch: 60, Value: 60},
+	}
+	timeResultEp := []int64{3, 10, 16, 40, 70}
+	tests := []struct {
+		name      string
+		mode      string
+		tolerance int64
+		want      []float64
+	}{
+		{"Nearest", csvdata.NEAREST, 0, []float64{0, 10, 30, 30, 60}},
+		{"NearestTolerance", csvdata.NEAREST, 5, []float64{0, 10, 30, math.NaN(), math.NaN()}},
+		{"Previous", csvdata.PREVIOUS, 0, []float64{0, 10, 10, 30, 60}},
+		{"PreviousTolerance", csvdata.PREVIOUS, 5, []float64{0, 10, math.NaN(), math.NaN(), math.NaN()}},
+		{"Next", csvdata.NEXT, 0, []float64{10, 10, 30, 60, math.NaN()}},
+		{"Linear", csvdata.LINEAR, 0, []float64{3, 10, 22, 45, math.NaN()}},
+		{"LinearTolerance", csvdata.LINEAR, 10, []float64{3, 10, 22, math.NaN(), math.NaN()}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			wg.Add(1)
+
+			reqcolumn := csvdata.SAColumn{
+				OutputColumnName: "pick",
+				PickRelative:     timeResultEp,
+				PickMode:         tt.mode,
+				PickToleranceEp:  tt.tolerance,
+				TimeResultEp:     &timeResultEp,
+				Result:           make([]float64, len(timeResultEp)),
+			}
+			sa := csvdata.NewSmartAggregator(csvdata.PICK, &reqcolumn, &wg)
+
+			go func() {
+				for _, d := range data {
+					sa.Data <- d
+				}
+				close(sa.Data)
+			}()
+
+			wg.Wait()
+
+			for i, v := range sa.Column.Result {
+				if math.Abs(v-tt.want[i]) > 1e-9 && !(math.IsNaN(v) && math.IsNaN(tt.want[i])) {
+					t.Errorf("pick %d got %v, want %v", i, v, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDataResample(t *testing.T) {
+	// drifting samples with a gap from 30 to 60
+	data := []csvdata.Input{
+		{Epoch: 1, Value: 0},
+		{Epoch: 9, Value: 1},
+		{Epoch: 11, Value: 1.5},
+		{Epoch: 21, Value: 2},
+		{Epoch: 59, Value: 6},
+	}
+	timeResultEp := []int64{0, 10, 20, 30, 40, 50, 60}
+	windowrelative := make([][2]int64, len(timeResultEp))
+	for i, v := range timeResultEp {
+		windowrelative[i] = [2]int64{v - 5, v + 4}
+	}
+	tests := []struct {
+		name   string
+		method string
+		limit  int64
+		want   []float64
+		filled []bool
+	}{
+		{"None", csvdata.NONE, 0, []float64{0, 1, 2, math.NaN(), math.NaN(), math.NaN(), 6}, nil},
+		{"Previous", csvdata.PREVIOUS, 0, []float64{0, 1, 2, 2, 2, 2, 6}, []bool{false, false, false, true, true, true, false}},
+		{"PreviousLimit", csvdata.PREVIOUS, 10, []float64{0, 1, 2, 2, math.NaN(), math.NaN(), 6}, []bool{false, false, false, true, false, false, false}},
+		{"Next", csvdata.NEXT, 0, []float64{0, 1, 2, 6, 6, 6, 6}, []bool{false, false, false, true, true, true, false}},
+		{"Linear", csvdata.LINEAR, 0, []float64{0, 1, 2, 3, 4, 5, 6}, []bool{false, false, false, true, true, true, false}},
+		{"NearestLimit", csvdata.NEAREST, 10, []float64{0, 1, 2, 2, math.NaN(), 6, 6}, []bool{false, false, false, true, false, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			wg.Add(1)
+
+			reqcolumn := csvdata.SAColumn{
+				OutputColumnName: "resample",
+				WindowRelative:   windowrelative,
+				FillMethod:       tt.method,
+				FillLimitEp:      tt.limit,
+				TimeResultEp:     &timeResultEp,
+				Result:           make([]float64, len(timeResultEp)),
+			}
+			sa := csvdata.NewSmartAggregator(csvdata.RESAMPLE, &reqcolumn, &wg)
+
+			go func() {
+				for _, d := range data {
+					sa.Data <- d
+				}
+				close(sa.Data)
+			}()
+
+			wg.Wait()
+
+			for i, v := range sa.Column.Result {
+				if v != tt.want[i] && !(math.IsNaN(v) && math.IsNaN(tt.want[i])) {
+					t.Errorf("cell %d got %v, want %v", i, v, tt.want[i])
+				}
+			}
+			for i, v := range tt.filled {
+				if sa.Column.Filled[i] != v {
+					t.Errorf("cell %d filled got %v, want %v", i, sa.Column.Filled[i], v)
+				}
+			}
+		})
+	}
+}
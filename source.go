@@ -0,0 +1,197 @@
+package csvdata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sourcedInput is the input column of a request and the source it is read from
+type sourcedInput struct {
+	source  *string
+	column  *string
+	aliases []string
+}
+
+// checkSources checks the source names and the source of every input. An input written as source.column,
+// with source the Name of a FileConfig, is split into its source and column. The source of an input without
+// a source is found in the headers of the files, see checkHeaders and sourceOwners.
+func checkSources(sources []FileConfig, inputs []sourcedInput) error {
+	names := make([]string, 0, len(sources))
+	for _, filec := range sources {
+		if filec.Name == "" {
+			continue
+		}
+		if StringInSlice(filec.Name, names) {
+			return fmt.Errorf("source name %s is used by several file configs", filec.Name)
+		}
+		names = append(names, filec.Name)
+	}
+
+	for _, inp := range inputs {
+		if *inp.source == "" {
+			if dot := strings.Index(*inp.column, "."); dot > 0 && StringInSlice((*inp.column)[:dot], names) {
+				*inp.source = (*inp.column)[:dot]
+				*inp.column = (*inp.column)[dot+1:]
+			}
+		}
+		if *inp.source != "" && !StringInSlice(*inp.source, names) {
+			return fmt.Errorf("source %s of input column %s is not the name of a file config", *inp.source, *inp.column)
+		}
+	}
+	return nil
+}
+
+// checkHeaders checks that every input without a source is in the header of only one source. Only the first
+// readable file of every source holding the rows from startUTC to endUTC is read, so Check stays cheap,
+// the other files are checked while they are read by sourceOwners.
+func checkHeaders(sources []FileConfig, inputs []sourcedInput, startUTC time.Time, endUTC time.Time) error {
+	if len(sources) < 2 {
+		return nil
+	}
+	reqInputs := make([]requestInput, len(inputs))
+	unqualified := false
+	for i, inp := range inputs {
+		reqInputs[i] = requestInput{column: *inp.column, aliases: inp.aliases, source: *inp.source}
+		unqualified = unqualified || *inp.source == ""
+	}
+	if !unqualified {
+		return nil
+	}
+
+	owners := newSourceOwners(sources, reqInputs)
+	for s := range sources {
+		for _, file := range sources[s].files(startUTC, endUTC) {
+			header, err := readHeader(file.name)
+			if err != nil {
+				continue
+			}
+			if err := owners.claim(s, file.fileColumns(header, reqInputs, sources[s].Name)); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// sourceOwners finds out the source of the inputs without a source while the files are read, such an input
+// must be found in the files of only one source. It is shared by the readers of every source.
+type sourceOwners struct {
+	mu      sync.Mutex
+	sources []FileConfig
+	inputs  []requestInput
+	owner   []int // the source the input i is found in, -1 when it is not found yet
+	err     error
+}
+
+func newSourceOwners(sources []FileConfig, inputs []requestInput) *sourceOwners {
+	owner := make([]int, len(inputs))
+	for i := range owner {
+		owner[i] = -1
+	}
+	return &sourceOwners{sources: sources, inputs: inputs, owner: owner}
+}
+
+// claim records the source s of the inputs found in a file of s, columns are the fileColumns of the file.
+// It returns an error when an input without a source is in the files of another source too, the error
+// is kept so the readers of the other sources stop as well.
+func (so *sourceOwners) claim(s int, columns []string) error {
+	if len(so.sources) < 2 {
+		return nil
+	}
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	if so.err != nil {
+		return so.err
+	}
+	for i, inp := range so.inputs {
+		if inp.source != "" || columns[i] == "" {
+			continue
+		}
+		if so.owner[i] == -1 {
+			so.owner[i] = s
+			continue
+		}
+		if so.owner[i] != s {
+			so.err = fmt.Errorf("input column %s is in the files of %s and %s, give it a source", inp.column, sourceName(so.sources, so.owner[i]), sourceName(so.sources, s))
+			return so.err
+		}
+	}
+	return nil
+}
+
+// failed returns the error of claim, nil when every input has one source so far
+func (so *sourceOwners) failed() error {
+	so.mu.Lock()
+	defer so.mu.Unlock()
+	return so.err
+}
+
+// sourceName names a source in the errors
+func sourceName(sources []FileConfig, s int) string {
+	if sources[s].Name != "" {
+		return sources[s].Name
+	}
+	if sources[s].FileNamingFormat != "" {
+		return sources[s].FileNamingFormat
+	}
+	return fmt.Sprintf("file config %d", s)
+}
+
+// requestInput is the input column of a request, its alternative names and its source
+type requestInput struct {
+	column  string
+	aliases []string
+	source  string
+}
+
+// column returns the name of the input column in the header of the file, empty when it is not found.
+// The name given by the period of the file comes first, then the input column and its aliases in order.
+func (file dataFile) column(header []string, inp requestInput) string {
+	if name, ok := file.columns[inp.column]; ok && findString(header, name) != -1 {
+		return name
+	}
+	if findString(header, inp.column) != -1 {
+		return inp.column
+	}
+	for _, alias := range inp.aliases {
+		if findString(header, alias) != -1 {
+			return alias
+		}
+	}
+	return ""
+}
+
+// fileColumns returns the column of every request in the header of a file of the source,
+// empty for the requests of another source and the columns not found
+func (file dataFile) fileColumns(header []string, inputs []requestInput, source string) []string {
+	columns := make([]string, len(inputs))
+	for i, inp := range inputs {
+		if inp.source == "" || inp.source == source {
+			columns[i] = file.column(header, inp)
+		}
+	}
+	return columns
+}
+
+// readHeader reads the column names of a csv file, from its index when it is up to date
+func readHeader(filename string) ([]string, error) {
+	csvfile, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer csvfile.Close()
+	info, err := csvfile.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if idx, err := readIndex(filename, info); err == nil {
+		return idx.columns, nil
+	}
+	header, _, err := readHeaderLine(bufio.NewReader(csvfile))
+	return header, err
+}
@@ -0,0 +1,157 @@
+package csvdata
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// sql dialects
+const (
+	SQLITE   = "sqlite"
+	POSTGRES = "postgres"
+	MYSQL    = "mysql"
+)
+
+// SQLOptions controls how WriteSQL upserts a SAResult
+type SQLOptions struct {
+	Table             string // name of the table, created from the requests when it is missing
+	Dialect           string // SQLITE, POSTGRES or MYSQL, empty means SQLITE
+	TimeColumnName    string // name of the timestamp column, empty means "time"
+	StationColumnName string // name of the station key column, empty means the table has no station key
+	Station           string // station key of every row
+}
+
+// WriteSQL upserts the rows of the SAResult into a table keyed by timestamp and the optional station key.
+// The table is created with a nullable double column for each request when it is missing,
+// NaN values are written as NULL. All the rows are written in one transaction.
+func (result SAResult) WriteSQL(db *sql.DB, opts SQLOptions) error {
+	if opts.Table == "" {
+		return fmt.Errorf("sql table is empty")
+	}
+	if opts.Dialect == "" {
+		opts.Dialect = SQLITE
+	}
+	if !StringInSlice(opts.Dialect, []string{SQLITE, POSTGRES, MYSQL}) {
+		return fmt.Errorf("sql dialect %s is not valid", opts.Dialect)
+	}
+	if opts.TimeColumnName == "" {
+		opts.TimeColumnName = "time"
+	}
+
+	colnames := result.ColumnNames()
+	keys := []string{opts.TimeColumnName}
+	if opts.StationColumnName != "" {
+		keys = append([]string{opts.StationColumnName}, keys...)
+	}
+	for _, key := range keys {
+		if StringInSlice(key, colnames) {
+			return fmt.Errorf("key column name %s is also an output column", key)
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(sqlCreateTable(opts, colnames)); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(sqlUpsert(opts, keys, colnames))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, 0, len(keys)+len(colnames))
+	for idx, dte := range *result.TimeStamp {
+		args = args[:0]
+		if opts.StationColumnName != "" {
+			args = append(args, opts.Station)
+		}
+		args = append(args, dte.UTC())
+		for _, colname := range colnames {
+			resValues := *result.Columns[colname]
+			if idx >= len(resValues) || math.IsNaN(resValues[idx]) || math.IsInf(resValues[idx], 0) {
+				args = append(args, nil)
+			} else {
+				args = append(args, resValues[idx])
+			}
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// sqlQuote quotes an identifier
+func sqlQuote(dialect string, name string) string {
+	if dialect == MYSQL {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func sqlCreateTable(opts SQLOptions, colnames []string) string {
+	timeType := "TIMESTAMP"
+	if opts.Dialect == MYSQL {
+		timeType = "DATETIME(6)"
+	}
+
+	defs := []string{}
+	keys := []string{}
+	if opts.StationColumnName != "" {
+		defs = append(defs, sqlQuote(opts.Dialect, opts.StationColumnName)+" VARCHAR(64) NOT NULL")
+		keys = append(keys, sqlQuote(opts.Dialect, opts.StationColumnName))
+	}
+	defs = append(defs, sqlQuote(opts.Dialect, opts.TimeColumnName)+" "+timeType+" NOT NULL")
+	keys = append(keys, sqlQuote(opts.Dialect, opts.TimeColumnName))
+	for _, colname := range colnames {
+		defs = append(defs, sqlQuote(opts.Dialect, colname)+" DOUBLE PRECISION")
+	}
+	defs = append(defs, "PRIMARY KEY ("+strings.Join(keys, ", ")+")")
+
+	return "CREATE TABLE IF NOT EXISTS " + sqlQuote(opts.Dialect, opts.Table) + " (" + strings.Join(defs, ", ") + ")"
+}
+
+func sqlUpsert(opts SQLOptions, keys []string, colnames []string) string {
+	all := append(append([]string{}, keys...), colnames...)
+	quoted := make([]string, len(all))
+	placeholders := make([]string, len(all))
+	for i, name := range all {
+		quoted[i] = sqlQuote(opts.Dialect, name)
+		if opts.Dialect == POSTGRES {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			placeholders[i] = "?"
+		}
+	}
+
+	query := "INSERT INTO " + sqlQuote(opts.Dialect, opts.Table) + " (" + strings.Join(quoted, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")"
+
+	updates := make([]string, len(colnames))
+	for i, q := range quoted[len(keys):] {
+		if opts.Dialect == MYSQL {
+			updates[i] = q + " = VALUES(" + q + ")"
+		} else {
+			updates[i] = q + " = excluded." + q
+		}
+	}
+	// nothing to update when there are only the keys
+	switch {
+	case opts.Dialect == MYSQL && len(updates) == 0:
+		query = strings.Replace(query, "INSERT INTO", "INSERT IGNORE INTO", 1)
+	case opts.Dialect == MYSQL:
+		query += " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	case len(updates) == 0:
+		query += " ON CONFLICT (" + strings.Join(quoted[:len(keys)], ", ") + ") DO NOTHING"
+	default:
+		query += " ON CONFLICT (" + strings.Join(quoted[:len(keys)], ", ") + ") DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+	return query
+}
@@ -0,0 +1,84 @@
+package csvdata_test
+
+import (
+	"database/sql"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+	_ "modernc.org/sqlite"
+)
+
+func TestWriteSQL(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// every connection has its own memory database
+	db.SetMaxOpenConns(1)
+
+	rain := []float64{1.25, math.NaN()}
+	temp := []float64{27, 26}
+	timestamp := []time.Time{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+	}
+	requests := []csvdata.RequestColumnTable{
+		{OutputColumnName: "Rain_08-16"},
+		{OutputColumnName: "temp"},
+	}
+	result := csvdata.SAResult{
+		Columns:   csvdata.Columns{"Rain_08-16": &rain, "temp": &temp},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+	opts := csvdata.SQLOptions{Table: "daily", StationColumnName: "station", Station: "96001"}
+
+	if err := result.WriteSQL(db, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// upsert the second day and write another station
+	rain[1] = 4
+	temp[1] = 25.5
+	if err := result.WriteSQL(db, opts); err != nil {
+		t.Fatal(err)
+	}
+	opts.Station = "96002"
+	if err := result.WriteSQL(db, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM daily`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("got %d rows, want 4", count)
+	}
+
+	var gotRain sql.NullFloat64
+	var gotTemp float64
+	err = db.QueryRow(`SELECT "Rain_08-16", temp FROM daily WHERE station = ? ORDER BY time DESC`, "96001").Scan(&gotRain, &gotTemp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotRain.Valid || gotRain.Float64 != 4 || gotTemp != 25.5 {
+		t.Errorf("got rain %v temp %v, want 4 and 25.5", gotRain, gotTemp)
+	}
+
+	// NaN is written as NULL
+	rain[0] = math.NaN()
+	if err := result.WriteSQL(db, opts); err != nil {
+		t.Fatal(err)
+	}
+	err = db.QueryRow(`SELECT "Rain_08-16" FROM daily WHERE station = ? ORDER BY time ASC`, "96002").Scan(&gotRain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotRain.Valid {
+		t.Errorf("got rain %v, want NULL", gotRain)
+	}
+}
@@ -0,0 +1,205 @@
+package csvdata
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SARow is one completed row of a table aggregation
+type SARow struct {
+	Time   time.Time
+	Values []float64 // in the order of SAStream.Columns, NaN when there is no value
+}
+
+// SAStream yields the rows of a table aggregation in time order while the files are read
+type SAStream struct {
+	Rows    <-chan SARow // closed after the last row, or after Close
+	Columns []string     // output column names in request order, computed columns come last
+	stop    chan struct{}
+	once    sync.Once
+
+	mu       sync.Mutex // guards the report of the reading, set by the reader goroutine
+	unsorted []string
+	dropped  map[string]int
+	err      error
+}
+
+// DroppedRows returns the rows that could not be put in time order by file, like SAResult.DroppedRows.
+// It is only complete after Rows is closed by the last row.
+func (s *SAStream) DroppedRows() map[string]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Err returns the error that stopped the reading, check it after Rows is closed.
+// The rows are not complete when it is set.
+func (s *SAStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// UnsortedFiles returns the files whose rows are not in time order, it is only complete after Rows is closed
+// by the last row. The rows of these files are sorted in runs before the aggregation, see DroppedRows.
+func (s *SAStream) UnsortedFiles() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unsorted
+}
+
+// Close stops reading the files, it must be called when the rows are not read to the end
+func (s *SAStream) Close() {
+	s.once.Do(func() {
+		close(s.stop)
+		// unblock the collector, the rows left are dropped
+		for range s.Rows {
+		}
+	})
+}
+
+// CsvAggregateTableStream is CsvAggregateTable without the whole result in memory. A row is sent
+// as soon as the windows of every column are closed, so only the open windows and the rows waiting
+// for a slower column are kept. The rows are the same as CsvAggregateTable, except that FillMethod is
+// not supported, because a gap can only be filled after the next observed value.
+// Every row read closes the windows ending before it for every column, also when the value is missing,
+// invalid or its column is not in the file.
+func CsvAggregateTableStream(cfg CsvAggregateTableConfigs) (*SAStream, error) {
+	// check if configs are valid
+	err := cfg.Check()
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range cfg.Requests {
+		if req.FillMethod != "" && req.FillMethod != NONE {
+			return nil, fmt.Errorf("fill method is not supported by the stream, column %s", req.OutputColumnName)
+		}
+	}
+
+	startTimeEpoch := TimetoEpoch(cfg.StartTime, cfg.TimePrecision)
+	endTimeEpoch := TimetoEpoch(cfg.EndTime, cfg.TimePrecision)
+	if endTimeEpoch < startTimeEpoch {
+		return nil, fmt.Errorf("no epoch to aggregate")
+	}
+	count := int((endTimeEpoch-startTimeEpoch)/cfg.AggWindowEp) + 1
+
+	// the column order of the rows
+	sares := SAResult{Requests: &cfg.Requests, Computed: &cfg.Computed}
+	columns := sares.ColumnNames()
+
+	// prepare for aggregation, every aggregator sends its results to the collector
+	var wg sync.WaitGroup
+	out := make(chan saOutput, 10*len(cfg.Requests))
+	samap := make(SAMap, len(cfg.Requests))
+	for i, req := range cfg.Requests {
+		wg.Add(1)
+		col := SAColumn{
+			OutputColumnName: req.OutputColumnName,
+			WindowRelativeEp: req.WindowEp,
+			PickRelativeEp:   req.PickEp,
+			PickMode:         req.PickMode,
+			PickToleranceEp:  req.PickToleranceEp,
+			TimeStartEp:      startTimeEpoch,
+			TimeStepEp:       cfg.AggWindowEp,
+			TimeCount:        count,
+			out:              out,
+			index:            i,
+		}
+		samap[req.OutputColumnName] = NewSmartAggregatorBatch(req.Method, &col, &wg)
+	}
+
+	rows := make(chan SARow)
+	stream := &SAStream{
+		Rows:    rows,
+		Columns: columns,
+		stop:    make(chan struct{}),
+	}
+
+	// read the files, the aggregators are closed after the unsorted files are set so they are set before Rows is closed
+	go func() {
+		report, err := cfg.feedTable(samap, stream.stop, true)
+		stream.mu.Lock()
+		stream.unsorted, stream.dropped, stream.err = report.unsorted, report.dropped, err
+		stream.mu.Unlock()
+		cfg.closeBatches(samap)
+	}()
+	// close the collector when all the aggregator are done
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	// collect the results into rows
+	go func() {
+		defer close(rows)
+		collectRows(out, rows, stream.stop, len(cfg.Requests), columns, cfg.Computed, func(i int) time.Time {
+			return EpochtoTime(startTimeEpoch+int64(i)*cfg.AggWindowEp, cfg.TimePrecision)
+		})
+	}()
+
+	return stream, nil
+}
+
+// pendingRow is a row waiting for the results of some columns
+type pendingRow struct {
+	values []float64
+	count  int
+}
+
+// collectRows assembles the results of ncol columns into rows, a row is sent when every column saved it.
+// Every column saves its results in order, so the pending rows are only the ones a slower column did not reach.
+func collectRows(out <-chan saOutput, rows chan<- SARow, stop <-chan struct{}, ncol int, columns []string, computed []RequestComputedColumn, timeAt func(i int) time.Time) {
+	// position of the computed inputs in the row
+	inputs := make([][]int, len(computed))
+	for c, req := range computed {
+		for _, inp := range req.InputColumnNames {
+			inputs[c] = append(inputs[c], findString(columns, inp))
+		}
+	}
+
+	// pending[k] is the row first+k
+	pending := []*pendingRow{}
+	first := 0
+	stopped := false
+
+	for res := range out {
+		if stopped {
+			// keep draining so the aggregators can finish
+			continue
+		}
+		for len(pending) <= res.index-first {
+			pending = append(pending, &pendingRow{values: make([]float64, ncol+len(computed))})
+		}
+		row := pending[res.index-first]
+		row.values[res.column] = res.value
+		row.count++
+
+		// send the completed rows
+		for len(pending) > 0 && pending[0].count == ncol {
+			values := pending[0].values
+			for c := range computed {
+				values[ncol+c] = computeRow(&computed[c], inputs[c], values)
+			}
+			select {
+			case rows <- SARow{Time: timeAt(first), Values: values}:
+			case <-stop:
+				stopped = true
+			}
+			if stopped {
+				break
+			}
+			pending[0] = nil
+			pending = pending[1:]
+			first++
+		}
+	}
+}
+
+// computeRow computes a computed column of one row, inputs are the positions of its input columns
+func computeRow(req *RequestComputedColumn, inputs []int, values []float64) float64 {
+	in := make([]float64, len(inputs))
+	for i, pos := range inputs {
+		in[i] = values[pos]
+	}
+	return req.compute(in)
+}
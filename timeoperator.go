@@ -9,6 +9,7 @@ const (
 	SECOND = "second"
 	MICRO  = "microsecond"
 	MILLI  = "millisecond"
+	NANO   = "nanosecond" // only used by the outputs, not as TimePrecision
 )
 
 // function to convert epoch to time
@@ -23,6 +24,9 @@ func EpochtoTime(epoch int64, precission string) time.Time {
 	case MILLI:
 		dtn := time.UnixMilli(epoch)
 		return dtn
+	case NANO:
+		dtn := time.Unix(0, epoch)
+		return dtn
 	default:
 		dtn := time.Unix(epoch, 0)
 		return dtn
@@ -38,6 +42,8 @@ func TimetoEpoch(t time.Time, precission string) int64 {
 		return t.UnixMicro()
 	case MILLI:
 		return t.UnixMilli()
+	case NANO:
+		return t.UnixNano()
 	default:
 		return t.Unix()
 	}
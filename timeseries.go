@@ -0,0 +1,268 @@
+package csvdata
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InfluxOptions controls how WriteInflux renders a SAResult as InfluxDB line protocol
+type InfluxOptions struct {
+	Measurement string            // measurement of every line
+	Tags        map[string]string // tags of every line, for example the station id
+	Fields      []string          // output columns written as fields, empty means all columns in request order
+	TimeUnit    string            // SECOND, MILLI, MICRO or NANO precision of the timestamps, empty means NANO
+	Token       string            // token of the Authorization header, only used by PushInflux
+}
+
+// PrometheusOptions controls how WritePrometheus renders the values in the Prometheus text exposition format
+type PrometheusOptions struct {
+	Prefix        string            // prefix of every metric name, for example "aws_"
+	Labels        map[string]string // labels of every metric, for example the station id
+	WithTimestamp bool              // write the time of the values as the sample timestamp
+}
+
+var influxMeasurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+var influxKeyEscaper = strings.NewReplacer(`,`, `\,`, `=`, `\=`, ` `, `\ `)
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteInflux writes one line per row of the SAResult in InfluxDB line protocol.
+// NaN and infinite values are left out, a row without any value is not written.
+func (result SAResult) WriteInflux(w io.Writer, opts InfluxOptions) error {
+	if opts.Measurement == "" {
+		return fmt.Errorf("influx measurement is empty")
+	}
+	if opts.TimeUnit == "" {
+		opts.TimeUnit = NANO
+	}
+	if !StringInSlice(opts.TimeUnit, []string{SECOND, MILLI, MICRO, NANO}) {
+		return fmt.Errorf("influx time unit %s is not valid", opts.TimeUnit)
+	}
+	fields := opts.Fields
+	if len(fields) == 0 {
+		fields = result.ColumnNames()
+	}
+	for _, field := range fields {
+		if _, ok := result.Columns[field]; !ok {
+			return fmt.Errorf("influx field %s is not an output column", field)
+		}
+	}
+
+	// the measurement and the sorted tags are the same for every line
+	var prefix strings.Builder
+	prefix.WriteString(influxMeasurementEscaper.Replace(opts.Measurement))
+	for _, key := range sortedKeys(opts.Tags) {
+		// line protocol has no empty tag key or value
+		if key == "" || opts.Tags[key] == "" {
+			return fmt.Errorf("influx tag %s=%s is empty", key, opts.Tags[key])
+		}
+		prefix.WriteString(",")
+		prefix.WriteString(influxKeyEscaper.Replace(key))
+		prefix.WriteString("=")
+		prefix.WriteString(influxKeyEscaper.Replace(opts.Tags[key]))
+	}
+	fieldKeys := make([]string, len(fields))
+	for i, field := range fields {
+		fieldKeys[i] = influxKeyEscaper.Replace(field)
+	}
+
+	var line bytes.Buffer
+	for idx, dte := range *result.TimeStamp {
+		line.Reset()
+		line.WriteString(prefix.String())
+		sep := " "
+		for i, field := range fields {
+			resValues := *result.Columns[field]
+			if idx >= len(resValues) || math.IsNaN(resValues[idx]) || math.IsInf(resValues[idx], 0) {
+				continue
+			}
+			line.WriteString(sep)
+			line.WriteString(fieldKeys[i])
+			line.WriteString("=")
+			line.WriteString(strconv.FormatFloat(resValues[idx], 'f', -1, 64))
+			sep = ","
+		}
+		// no field in the row
+		if sep == " " {
+			continue
+		}
+		line.WriteString(" ")
+		line.WriteString(strconv.FormatInt(TimetoEpoch(dte, opts.TimeUnit), 10))
+		line.WriteString("\n")
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PushInflux posts the line protocol of the SAResult to url, the write endpoint of InfluxDB
+// including the database or bucket and the precision matching opts.TimeUnit
+func (result SAResult) PushInflux(client *http.Client, url string, opts InfluxOptions) error {
+	var buf bytes.Buffer
+	if err := result.WriteInflux(&buf, opts); err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {"text/plain; charset=utf-8"}}
+	if opts.Token != "" {
+		header.Set("Authorization", "Token "+opts.Token)
+	}
+	return pushHTTP(client, http.MethodPost, url, header, &buf)
+}
+
+// WritePrometheus writes the latest row of the SAResult as gauges in the Prometheus text exposition format
+func (result SAResult) WritePrometheus(w io.Writer, opts PrometheusOptions) error {
+	if result.TimeStamp == nil || len(*result.TimeStamp) == 0 {
+		return nil
+	}
+	last := len(*result.TimeStamp) - 1
+	colnames := result.ColumnNames()
+	values := make(map[string]float64, len(colnames))
+	for _, colname := range colnames {
+		resValues, ok := result.Columns[colname]
+		if !ok || resValues == nil {
+			return fmt.Errorf("output column %s is not in the result", colname)
+		}
+		if last < len(*resValues) {
+			values[colname] = (*resValues)[last]
+		}
+	}
+	return writePrometheus(w, colnames, values, TimetoEpoch((*result.TimeStamp)[last], MILLI), opts)
+}
+
+// WritePrometheusPoint writes the output of CsvAggregatePoint as gauges in the Prometheus text exposition format,
+// the metrics are sorted by name and the timestamp is only used when opts.WithTimestamp is set
+func WritePrometheusPoint(w io.Writer, values map[string]float64, timestampMs int64, opts PrometheusOptions) error {
+	return writePrometheus(w, sortedKeys(values), values, timestampMs, opts)
+}
+
+// PushPrometheus puts the latest row of the SAResult to url, a Pushgateway group such as http://host:9091/metrics/job/aws
+func (result SAResult) PushPrometheus(client *http.Client, url string, opts PrometheusOptions) error {
+	var buf bytes.Buffer
+	if err := result.WritePrometheus(&buf, opts); err != nil {
+		return err
+	}
+	return pushHTTP(client, http.MethodPut, url, http.Header{"Content-Type": {"text/plain; version=0.0.4"}}, &buf)
+}
+
+// PushPrometheusPoint puts the output of CsvAggregatePoint to url, a Pushgateway group
+func PushPrometheusPoint(client *http.Client, url string, values map[string]float64, timestampMs int64, opts PrometheusOptions) error {
+	var buf bytes.Buffer
+	if err := WritePrometheusPoint(&buf, values, timestampMs, opts); err != nil {
+		return err
+	}
+	return pushHTTP(client, http.MethodPut, url, http.Header{"Content-Type": {"text/plain; version=0.0.4"}}, &buf)
+}
+
+// writePrometheus writes the values in names order, NaN values are left out.
+// Names or labels that become the same metric or label name are an error.
+func writePrometheus(w io.Writer, names []string, values map[string]float64, timestampMs int64, opts PrometheusOptions) error {
+	metrics := make(map[string]string, len(names))
+	for _, name := range names {
+		metric := promName(opts.Prefix + name)
+		if other, ok := metrics[metric]; ok {
+			return fmt.Errorf("output columns %s and %s are both the metric %s", other, name, metric)
+		}
+		metrics[metric] = name
+	}
+	labelNames := make(map[string]string, len(opts.Labels))
+	for key := range opts.Labels {
+		label := promName(key)
+		if other, ok := labelNames[label]; ok {
+			return fmt.Errorf("labels %s and %s are both the label %s", other, key, label)
+		}
+		labelNames[label] = key
+	}
+
+	var labels strings.Builder
+	for i, key := range sortedKeys(opts.Labels) {
+		if i == 0 {
+			labels.WriteString("{")
+		} else {
+			labels.WriteString(",")
+		}
+		labels.WriteString(promName(key))
+		labels.WriteString(`="`)
+		labels.WriteString(promLabelEscaper.Replace(opts.Labels[key]))
+		labels.WriteString(`"`)
+	}
+	if labels.Len() > 0 {
+		labels.WriteString("}")
+	}
+
+	var buf bytes.Buffer
+	for _, name := range names {
+		value, ok := values[name]
+		if !ok || math.IsNaN(value) {
+			continue
+		}
+		metric := promName(opts.Prefix + name)
+		fmt.Fprintf(&buf, "# TYPE %s gauge\n%s%s %s", metric, metric, labels.String(), promFloat(value))
+		if opts.WithTimestamp {
+			buf.WriteString(" ")
+			buf.WriteString(strconv.FormatInt(timestampMs, 10))
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// promName replaces the characters not allowed in a metric or label name with _
+func promName(name string) string {
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || c == ':' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func promFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pushHTTP sends the body to url, a response other than 2xx is an error
+func pushHTTP(client *http.Client, method string, url string, header http.Header, body io.Reader) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("push to %s failed: %s %s", url, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package csvdata_test
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luhtfiimanal/csvdata"
+)
+
+func timeseriesResult() csvdata.SAResult {
+	rain := []float64{1.25, math.NaN(), math.NaN()}
+	temp := []float64{27, 26, math.NaN()}
+	timestamp := []time.Time{
+		time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC),
+		time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+	}
+	requests := []csvdata.RequestColumnTable{
+		{OutputColumnName: "air temp"},
+		{OutputColumnName: "rain"},
+	}
+	return csvdata.SAResult{
+		Columns:   csvdata.Columns{"rain": &rain, "air temp": &temp},
+		Requests:  &requests,
+		TimeStamp: &timestamp,
+	}
+}
+
+func TestWriteInflux(t *testing.T) {
+	result := timeseriesResult()
+	var buf bytes.Buffer
+	err := result.WriteInflux(&buf, csvdata.InfluxOptions{
+		Measurement: "aws daily",
+		Tags:        map[string]string{"station": "st,01", "network": "aws"},
+		TimeUnit:    csvdata.SECOND,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "aws\\ daily,network=aws,station=st\\,01 air\\ temp=27,rain=1.25 1672531200\n" +
+		"aws\\ daily,network=aws,station=st\\,01 air\\ temp=26 1672617600\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	result := timeseriesResult()
+	// the latest row has no value
+	*result.TimeStamp = (*result.TimeStamp)[:2]
+
+	var buf bytes.Buffer
+	err := result.WritePrometheus(&buf, csvdata.PrometheusOptions{
+		Prefix:        "aws_",
+		Labels:        map[string]string{"station": `st "01"`},
+		WithTimestamp: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# TYPE aws_air_temp gauge\naws_air_temp{station=\"st \\\"01\\\"\"} 26 1672617600000\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	err = csvdata.WritePrometheusPoint(&buf, map[string]float64{"water_level": 51.5, "dewpoint": 23}, 0, csvdata.PrometheusOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = "# TYPE dewpoint gauge\ndewpoint 23\n# TYPE water_level gauge\nwater_level 51.5\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestTimeseries_Invalid(t *testing.T) {
+	result := timeseriesResult()
+
+	// line protocol has no empty tag value
+	var buf bytes.Buffer
+	err := result.WriteInflux(&buf, csvdata.InfluxOptions{Measurement: "aws", Tags: map[string]string{"station": ""}})
+	if err == nil {
+		t.Errorf("no error for the empty tag, wrote\n%s", buf.String())
+	}
+
+	// air temp and air_temp are both the metric aws_air_temp
+	values := map[string]float64{"air temp": 27, "air_temp": 26}
+	buf.Reset()
+	if err := csvdata.WritePrometheusPoint(&buf, values, 0, csvdata.PrometheusOptions{Prefix: "aws_"}); err == nil {
+		t.Errorf("no error for the same metric, wrote\n%s", buf.String())
+	}
+	values = map[string]float64{"temp": 27}
+	labels := map[string]string{"station id": "01", "station_id": "02"}
+	if err := csvdata.WritePrometheusPoint(&buf, values, 0, csvdata.PrometheusOptions{Labels: labels}); err == nil {
+		t.Error("no error for the same label")
+	}
+}
+
+func TestPushTimeseries(t *testing.T) {
+	var method, auth, body string
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		auth = r.Header.Get("Authorization")
+		b, _ := io.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	result := timeseriesResult()
+	err := result.PushInflux(server.Client(), server.URL+"/api/v2/write?bucket=aws&precision=s", csvdata.InfluxOptions{
+		Measurement: "aws",
+		Fields:      []string{"rain"},
+		TimeUnit:    csvdata.SECOND,
+		Token:       "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost || auth != "Token secret" || body != "aws rain=1.25 1672531200\n" {
+		t.Errorf("got %s %q %q", method, auth, body)
+	}
+
+	err = csvdata.PushPrometheusPoint(server.Client(), server.URL+"/metrics/job/aws", map[string]float64{"rain": 2}, 0, csvdata.PrometheusOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || body != "# TYPE rain gauge\nrain 2\n" {
+		t.Errorf("got %s %q", method, body)
+	}
+
+	// the server rejects the push
+	status = http.StatusBadRequest
+	if err := result.PushPrometheus(server.Client(), server.URL+"/metrics/job/aws", csvdata.PrometheusOptions{}); err == nil {
+		t.Error("expected error for a rejected push")
+	}
+}